@@ -111,6 +111,9 @@ func (b *Builder) Add(destination string, extract bool, source ...string) error
 			if err != nil {
 				return errors.Wrapf(err, "error parsing URL %q", src)
 			}
+			if err = b.HostPolicy.Check("add", src, url.Host); err != nil {
+				return err
+			}
 			d := dest
 			if destfi != nil && destfi.IsDir() {
 				d = filepath.Join(dest, path.Base(url.Path))
@@ -0,0 +1,233 @@
+package buildah
+
+import (
+	"encoding/json"
+	"sort"
+
+	"github.com/containers/image/manifest"
+	"github.com/containers/image/transports"
+	"github.com/containers/image/transports/alltransports"
+	"github.com/containers/image/types"
+	"github.com/containers/storage"
+	digest "github.com/opencontainers/go-digest"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// BaseImageNameAnnotation is the manifest annotation in which we
+	// record the name of the image which an image was built from.
+	BaseImageNameAnnotation = "org.opencontainers.image.base.name"
+	// BaseImageDigestAnnotation is the manifest annotation in which we
+	// record the digest of the manifest of the image which an image was
+	// built from.
+	BaseImageDigestAnnotation = "org.opencontainers.image.base.digest"
+)
+
+// ImageUsage describes how a local image is related to other local images and
+// to working containers.
+type ImageUsage struct {
+	// ID is the image's ID.
+	ID string `json:"id"`
+	// Names is the list of the image's names.
+	Names []string `json:"names,omitempty"`
+	// Parent is the ID of the local image which the image was built from,
+	// if we know which one it was.
+	Parent string `json:"parent,omitempty"`
+	// Children is the list of the IDs of the local images which were built
+	// from the image.
+	Children []string `json:"children,omitempty"`
+	// Containers is the list of the names of the working containers which
+	// were created from the image.
+	Containers []string `json:"containers,omitempty"`
+}
+
+// manifestDigest computes the digest of a manifest, or returns an empty string
+// if there isn't one.
+func manifestDigest(manifestBytes []byte) string {
+	if len(manifestBytes) == 0 {
+		return ""
+	}
+	d, err := manifest.Digest(manifestBytes)
+	if err != nil {
+		logrus.Debugf("error computing digest of manifest: %v", err)
+		return ""
+	}
+	return d.String()
+}
+
+// parseImageName parses an image reference, which is assumed to refer to an
+// image in a registry if it doesn't specify a transport.
+func parseImageName(image string) (types.ImageReference, error) {
+	ref, err := alltransports.ParseImageName(image)
+	if err != nil {
+		ref2, err2 := alltransports.ParseImageName(DefaultTransport + image)
+		if err2 != nil {
+			return nil, errors.Wrapf(err, "error parsing image name %q", image)
+		}
+		ref = ref2
+	}
+	return ref, nil
+}
+
+// withBaseImage returns a copy of the builder which records a different image,
+// usually a mirrored copy of the one which the container was created from, as
+// its base image.  The layers of the other image must be the same as those of
+// the image which the container was created from, so that the image which is
+// committed can be used in place of one based on it.
+func (b *Builder) withBaseImage(image string, sc *types.SystemContext) (*Builder, error) {
+	ref, err := parseImageName(image)
+	if err != nil {
+		return nil, err
+	}
+	if err = b.HostPolicy.CheckReference("pull", ref); err != nil {
+		return nil, err
+	}
+	img, err := ref.NewImage(sc)
+	if err != nil {
+		return nil, errors.Wrapf(err, "error reading image %q", transports.ImageName(ref))
+	}
+	defer img.Close()
+	manifestBytes, _, err := img.Manifest()
+	if err != nil {
+		return nil, errors.Wrapf(err, "error reading manifest of image %q", transports.ImageName(ref))
+	}
+	config, err := img.OCIConfig()
+	if err != nil {
+		return nil, errors.Wrapf(err, "error reading configuration of image %q", transports.ImageName(ref))
+	}
+	if !sameDiffIDs(config.RootFS.DiffIDs, b.OCIv1.RootFS.DiffIDs) {
+		from := b.FromImage
+		if from == "" {
+			from = "scratch"
+		}
+		return nil, errors.Errorf("the layers of image %q are not the same as those of %q, which container %q was created from", transports.ImageName(ref), from, b.Container)
+	}
+	rebased := *b
+	rebased.FromImage = image
+	if named := ref.DockerReference(); named != nil {
+		rebased.FromImage = named.String()
+	}
+	rebased.FromImageDigest = manifestDigest(manifestBytes)
+	rebased.FromImageID = img.ConfigInfo().Digest.Hex()
+	return &rebased, nil
+}
+
+// sameDiffIDs returns true if two lists of layer diffIDs are the same.
+func sameDiffIDs(a, b []digest.Digest) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// imageManifestInfo is the subset of a manifest which we need to find an
+// image's configuration and what it was built from.
+type imageManifestInfo struct {
+	Config struct {
+		Digest string `json:"digest"`
+	} `json:"config"`
+	Annotations map[string]string `json:"annotations,omitempty"`
+}
+
+// ImageUsages examines every image in local storage, and every working
+// container, and returns a map from image IDs to descriptions of which other
+// images and which working containers were built from them.  An image's base
+// is identified using the digest which we record in its manifest when we
+// commit it, or failing that, the parent image ID in its configuration.
+func ImageUsages(store storage.Store) (map[string]*ImageUsage, error) {
+	images, err := store.Images()
+	if err != nil {
+		return nil, errors.Wrapf(err, "error reading list of images")
+	}
+	usages := make(map[string]*ImageUsage)
+	byDigest := make(map[string]string)
+	manifests := make(map[string]imageManifestInfo)
+	for _, image := range images {
+		usages[image.ID] = &ImageUsage{
+			ID:    image.ID,
+			Names: image.Names,
+		}
+		manifestBytes, err := store.ImageBigData(image.ID, "manifest")
+		if err != nil {
+			logrus.Debugf("error reading manifest of image %q: %v", image.ID, err)
+			continue
+		}
+		if d := manifestDigest(manifestBytes); d != "" {
+			byDigest[d] = image.ID
+		}
+		info := imageManifestInfo{}
+		if err = json.Unmarshal(manifestBytes, &info); err != nil {
+			logrus.Debugf("error parsing manifest of image %q: %v", image.ID, err)
+			continue
+		}
+		manifests[image.ID] = info
+	}
+	for id, info := range manifests {
+		parent := ""
+		if d, ok := info.Annotations[BaseImageDigestAnnotation]; ok {
+			parent = byDigest[d]
+		}
+		if parent == "" && info.Config.Digest != "" {
+			config := struct {
+				Parent string `json:"parent,omitempty"`
+			}{}
+			configBytes, err := store.ImageBigData(id, info.Config.Digest)
+			if err == nil && json.Unmarshal(configBytes, &config) == nil && config.Parent != "" {
+				if d, err := digest.Parse(config.Parent); err == nil {
+					parent = d.Hex()
+				} else {
+					parent = config.Parent
+				}
+			}
+		}
+		if _, ok := usages[parent]; !ok || parent == id {
+			continue
+		}
+		usages[id].Parent = parent
+		usages[parent].Children = append(usages[parent].Children, id)
+	}
+	builders, err := OpenAllBuilders(store)
+	if err != nil {
+		return nil, errors.Wrapf(err, "error reading build containers")
+	}
+	for _, builder := range builders {
+		if usage, ok := usages[builder.FromImageID]; ok {
+			usage.Containers = append(usage.Containers, builder.Container)
+		}
+	}
+	for _, usage := range usages {
+		sort.Strings(usage.Children)
+		sort.Strings(usage.Containers)
+	}
+	return usages, nil
+}
+
+// ImageDescendants returns the IDs of the images which were built, directly
+// or indirectly, from the specified image, in the order in which they would
+// need to be rebuilt if it changed.
+func ImageDescendants(usages map[string]*ImageUsage, id string) []string {
+	descendants := []string{}
+	seen := map[string]bool{id: true}
+	queue := []string{id}
+	for len(queue) > 0 {
+		usage, ok := usages[queue[0]]
+		queue = queue[1:]
+		if !ok {
+			continue
+		}
+		for _, child := range usage.Children {
+			if !seen[child] {
+				seen[child] = true
+				descendants = append(descendants, child)
+				queue = append(queue, child)
+			}
+		}
+	}
+	return descendants
+}
@@ -0,0 +1,26 @@
+package buildah
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestImageDescendants(t *testing.T) {
+	usages := map[string]*ImageUsage{
+		"base":       {ID: "base", Children: []string{"child1", "child2"}},
+		"child1":     {ID: "child1", Parent: "base", Children: []string{"grandchild"}},
+		"child2":     {ID: "child2", Parent: "base"},
+		"grandchild": {ID: "grandchild", Parent: "child1"},
+		"other":      {ID: "other"},
+	}
+	for id, expected := range map[string][]string{
+		"base":       {"child1", "child2", "grandchild"},
+		"child1":     {"grandchild"},
+		"grandchild": {},
+		"missing":    {},
+	} {
+		if descendants := ImageDescendants(usages, id); !reflect.DeepEqual(descendants, expected) {
+			t.Errorf("expected descendants of %q to be %v, got %v", id, expected, descendants)
+		}
+	}
+}
@@ -0,0 +1,307 @@
+package buildah
+
+import (
+	"crypto/tls"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/containers/image/docker/reference"
+	"github.com/containers/image/image"
+	"github.com/containers/image/transports"
+	"github.com/containers/image/types"
+	digest "github.com/opencontainers/go-digest"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+// ErrBlobNotFound is returned by a BlobFetcher which doesn't have a blob.
+var ErrBlobNotFound = errors.New("blob not found")
+
+// mirrorResponseTimeout is how long we wait for a registry mirror to start
+// sending a blob.
+const mirrorResponseTimeout = 30 * time.Second
+
+// BlobFetcher is an alternate source for the layers and configuration blobs of
+// images which are being pulled, such as a cache or a registry mirror which is
+// closer than the image's registry.  When an image is pulled, each of the
+// fetchers in BuilderOptions.BlobFetchers is asked for each blob in turn, and
+// the blob is only read from the image's registry if none of them have it.
+// The manifest is always read from the image's registry, and blobs are always
+// checked against the digests which it lists, so fetchers don't need to be
+// trusted.
+type BlobFetcher interface {
+	// FetchBlob returns a blob, and its size, or -1 if the size isn't
+	// known, or ErrBlobNotFound if the fetcher doesn't have the blob.  ref
+	// is the image which is being pulled.
+	FetchBlob(ref types.ImageReference, info types.BlobInfo) (io.ReadCloser, int64, error)
+}
+
+// BlobSaver is implemented by BlobFetchers which can keep copies of blobs
+// which were read from somewhere else, so that they don't need to be read from
+// there again.
+type BlobSaver interface {
+	// SaveBlob returns a reader which returns the contents of rc, and which
+	// saves a copy of them once they've all been read and verified.
+	SaveBlob(info types.BlobInfo, rc io.ReadCloser) io.ReadCloser
+}
+
+// BlobCache is a BlobFetcher which keeps blobs in a directory, which can be
+// shared by any number of builds, and with other hosts using a network
+// filesystem.  Blobs which are read from other sources while pulling are
+// added to it.
+type BlobCache struct {
+	dir string
+}
+
+// NewBlobCache returns a BlobCache which keeps blobs in the specified
+// directory, creating it if it doesn't already exist.
+func NewBlobCache(dir string) (*BlobCache, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, errors.Wrapf(err, "error creating blob cache directory %q", dir)
+	}
+	return &BlobCache{dir: dir}, nil
+}
+
+func (c *BlobCache) blobPath(d digest.Digest) (string, error) {
+	if err := d.Validate(); err != nil {
+		return "", errors.Wrapf(err, "invalid blob digest %q", d)
+	}
+	return filepath.Join(c.dir, d.Algorithm().String(), d.Hex()), nil
+}
+
+// FetchBlob returns a blob from the cache.
+func (c *BlobCache) FetchBlob(ref types.ImageReference, info types.BlobInfo) (io.ReadCloser, int64, error) {
+	path, err := c.blobPath(info.Digest)
+	if err != nil {
+		return nil, -1, err
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, -1, ErrBlobNotFound
+		}
+		return nil, -1, errors.Wrapf(err, "error opening cached blob %q", path)
+	}
+	st, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, -1, errors.Wrapf(err, "error checking size of cached blob %q", path)
+	}
+	return f, st.Size(), nil
+}
+
+// SaveBlob returns a reader which adds a blob to the cache once it has been
+// read, if its contents match its digest.
+func (c *BlobCache) SaveBlob(info types.BlobInfo, rc io.ReadCloser) io.ReadCloser {
+	path, err := c.blobPath(info.Digest)
+	if err != nil {
+		logrus.Debugf("not caching blob: %v", err)
+		return rc
+	}
+	if err = os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		logrus.Debugf("not caching blob %q: %v", info.Digest, err)
+		return rc
+	}
+	f, err := ioutil.TempFile(filepath.Dir(path), ".tmp-")
+	if err != nil {
+		logrus.Debugf("not caching blob %q: %v", info.Digest, err)
+		return rc
+	}
+	return &savingReader{
+		ReadCloser: rc,
+		file:       f,
+		verifier:   info.Digest.Verifier(),
+		path:       path,
+	}
+}
+
+// savingReader copies what it reads to a temporary file, which it renames to
+// its final location if everything was read and it matched the expected digest.
+type savingReader struct {
+	io.ReadCloser
+	file     *os.File
+	verifier digest.Verifier
+	path     string
+}
+
+func (r *savingReader) discard() {
+	if r.file != nil {
+		r.file.Close()
+		os.Remove(r.file.Name())
+		r.file = nil
+	}
+}
+
+func (r *savingReader) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+	if r.file != nil && n > 0 {
+		r.verifier.Write(p[:n])
+		if _, err2 := r.file.Write(p[:n]); err2 != nil {
+			logrus.Debugf("error caching blob at %q: %v", r.path, err2)
+			r.discard()
+		}
+	}
+	if r.file != nil && err == io.EOF {
+		if !r.verifier.Verified() {
+			logrus.Debugf("not caching blob at %q: digest mismatch", r.path)
+			r.discard()
+			return n, err
+		}
+		name := r.file.Name()
+		err2 := r.file.Close()
+		r.file = nil
+		if err2 == nil {
+			err2 = os.Rename(name, r.path)
+		}
+		if err2 != nil {
+			logrus.Debugf("error caching blob at %q: %v", r.path, err2)
+			os.Remove(name)
+		}
+	}
+	return n, err
+}
+
+func (r *savingReader) Close() error {
+	r.discard()
+	return r.ReadCloser.Close()
+}
+
+// RegistryMirror is a BlobFetcher which reads blobs from a server which
+// implements the blob-fetching part of the registry API, such as a registry
+// which is configured as a pull-through cache, or a peer-to-peer distribution
+// agent like Dragonfly or Spegel.  Requests include the image's registry in an
+// "ns" query parameter, so that one mirror can serve more than one registry.
+type RegistryMirror struct {
+	url    *url.URL
+	client *http.Client
+}
+
+// NewRegistryMirror returns a RegistryMirror which reads from the server at
+// the specified http or https URL.
+func NewRegistryMirror(mirror string, insecureSkipTLSVerify bool) (*RegistryMirror, error) {
+	u, err := url.Parse(mirror)
+	if err != nil {
+		return nil, errors.Wrapf(err, "error parsing registry mirror URL %q", mirror)
+	}
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, errors.Errorf("invalid registry mirror URL %q: expected an http or https URL", mirror)
+	}
+	transport := &http.Transport{
+		Proxy: http.ProxyFromEnvironment,
+		// Blobs can be large, so only limit how long we wait for the
+		// server to start sending one.
+		ResponseHeaderTimeout: mirrorResponseTimeout,
+		TLSClientConfig:       &tls.Config{InsecureSkipVerify: insecureSkipTLSVerify},
+	}
+	return &RegistryMirror{
+		url:    u,
+		client: &http.Client{Transport: transport},
+	}, nil
+}
+
+// Host returns the host name and port of the mirror.
+func (m *RegistryMirror) Host() string {
+	return m.url.Host
+}
+
+// FetchBlob requests a blob from the mirror.
+func (m *RegistryMirror) FetchBlob(ref types.ImageReference, info types.BlobInfo) (io.ReadCloser, int64, error) {
+	if ref == nil || ref.Transport().Name() != "docker" || ref.DockerReference() == nil {
+		return nil, -1, ErrBlobNotFound
+	}
+	if err := info.Digest.Validate(); err != nil {
+		return nil, -1, errors.Wrapf(err, "invalid blob digest %q", info.Digest)
+	}
+	named := ref.DockerReference()
+	u := *m.url
+	u.Path = strings.TrimSuffix(u.Path, "/") + fmt.Sprintf("/v2/%s/blobs/%s", reference.Path(named), info.Digest)
+	u.RawQuery = url.Values{"ns": []string{reference.Domain(named)}}.Encode()
+	req, err := http.NewRequest("GET", u.String(), nil)
+	if err != nil {
+		return nil, -1, errors.Wrapf(err, "error building request for %q", u.String())
+	}
+	resp, err := m.client.Do(req)
+	if err != nil {
+		return nil, -1, errors.Wrapf(err, "error requesting blob from %q", u.Host)
+	}
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return resp.Body, resp.ContentLength, nil
+	case http.StatusNotFound:
+		resp.Body.Close()
+		return nil, -1, ErrBlobNotFound
+	}
+	resp.Body.Close()
+	return nil, -1, errors.Errorf("error requesting blob from %q: %s", u.Host, resp.Status)
+}
+
+// fetchingReference wraps an image reference so that blobs of the image are
+// read using a list of BlobFetchers when they can be.
+type fetchingReference struct {
+	types.ImageReference
+	fetchers []BlobFetcher
+}
+
+func (r *fetchingReference) NewImage(ctx *types.SystemContext) (types.Image, error) {
+	src, err := r.NewImageSource(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return image.FromSource(src)
+}
+
+func (r *fetchingReference) NewImageSource(ctx *types.SystemContext) (types.ImageSource, error) {
+	src, err := r.ImageReference.NewImageSource(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &fetchingSource{ImageSource: src, ref: r, fetchers: r.fetchers}, nil
+}
+
+// fetchingSource is an image source which tries to read blobs using a list of
+// BlobFetchers before reading them from the underlying source.
+type fetchingSource struct {
+	types.ImageSource
+	ref      *fetchingReference
+	fetchers []BlobFetcher
+}
+
+func (s *fetchingSource) Reference() types.ImageReference {
+	return s.ref
+}
+
+func (s *fetchingSource) GetBlob(info types.BlobInfo) (io.ReadCloser, int64, error) {
+	for _, fetcher := range s.fetchers {
+		rc, size, err := fetcher.FetchBlob(s.ref.ImageReference, info)
+		if err == nil {
+			logrus.Debugf("read blob %q for %q using %T", info.Digest, transports.ImageName(s.ref.ImageReference), fetcher)
+			return s.save(info, rc, fetcher), size, nil
+		}
+		if errors.Cause(err) != ErrBlobNotFound {
+			logrus.Warnf("error reading blob %q using %T, trying elsewhere: %v", info.Digest, fetcher, err)
+		}
+	}
+	rc, size, err := s.ImageSource.GetBlob(info)
+	if err != nil {
+		return nil, -1, err
+	}
+	return s.save(info, rc, nil), size, nil
+}
+
+// save arranges for every BlobSaver, other than the one which the blob is being
+// read from, to keep a copy of the blob.
+func (s *fetchingSource) save(info types.BlobInfo, rc io.ReadCloser, from BlobFetcher) io.ReadCloser {
+	for _, fetcher := range s.fetchers {
+		if saver, ok := fetcher.(BlobSaver); ok && fetcher != from {
+			rc = saver.SaveBlob(info, rc)
+		}
+	}
+	return rc
+}
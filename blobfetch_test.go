@@ -0,0 +1,128 @@
+package buildah
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/containers/image/transports/alltransports"
+	"github.com/containers/image/types"
+	digest "github.com/opencontainers/go-digest"
+	"github.com/pkg/errors"
+)
+
+func TestBlobCache(t *testing.T) {
+	dir, err := ioutil.TempDir("", "buildah-blobs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	imageDir := filepath.Join(dir, "image")
+	if err = os.Mkdir(imageDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	blob := []byte("layer contents")
+	info := types.BlobInfo{Digest: digest.FromBytes(blob), Size: -1}
+	if err = ioutil.WriteFile(filepath.Join(imageDir, info.Digest.Hex()+".tar"), blob, 0644); err != nil {
+		t.Fatal(err)
+	}
+	ref, err := alltransports.ParseImageName("dir:" + imageDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cache, err := NewBlobCache(filepath.Join(dir, "cache"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err = cache.FetchBlob(ref, info); errors.Cause(err) != ErrBlobNotFound {
+		t.Fatalf("expected ErrBlobNotFound from an empty cache, got %v", err)
+	}
+
+	read := func() []byte {
+		src, err := (&fetchingReference{ImageReference: ref, fetchers: []BlobFetcher{cache}}).NewImageSource(nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer src.Close()
+		rc, _, err := src.GetBlob(info)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer rc.Close()
+		data, err := ioutil.ReadAll(rc)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return data
+	}
+
+	// The first read comes from the image, and saves a copy.
+	if data := read(); string(data) != string(blob) {
+		t.Fatalf("expected %q, got %q", blob, data)
+	}
+	// The second read has to come from the cache.
+	if err = os.Remove(filepath.Join(imageDir, info.Digest.Hex()+".tar")); err != nil {
+		t.Fatal(err)
+	}
+	if data := read(); string(data) != string(blob) {
+		t.Fatalf("expected %q from the cache, got %q", blob, data)
+	}
+
+	// Blobs which don't match their digests aren't saved.
+	bad := types.BlobInfo{Digest: digest.FromString("something else"), Size: -1}
+	rc := cache.SaveBlob(bad, ioutil.NopCloser(bytes.NewReader(blob)))
+	if _, err = ioutil.ReadAll(rc); err != nil {
+		t.Fatal(err)
+	}
+	rc.Close()
+	if _, _, err = cache.FetchBlob(ref, bad); errors.Cause(err) != ErrBlobNotFound {
+		t.Fatalf("expected a mismatched blob to not be cached, got %v", err)
+	}
+}
+
+func TestRegistryMirror(t *testing.T) {
+	blob := []byte("layer contents")
+	d := digest.FromBytes(blob)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v2/library/alpine/blobs/"+d.String() || r.URL.Query().Get("ns") != "docker.io" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write(blob)
+	}))
+	defer server.Close()
+
+	mirror, err := NewRegistryMirror(server.URL, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ref, err := alltransports.ParseImageName("docker://alpine:latest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	rc, size, err := mirror.FetchBlob(ref, types.BlobInfo{Digest: d, Size: -1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := ioutil.ReadAll(rc)
+	rc.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != string(blob) || size != int64(len(blob)) {
+		t.Fatalf("expected %q (%d bytes), got %q (%d bytes)", blob, len(blob), data, size)
+	}
+	if _, _, err = mirror.FetchBlob(ref, types.BlobInfo{Digest: digest.FromString("missing"), Size: -1}); errors.Cause(err) != ErrBlobNotFound {
+		t.Fatalf("expected ErrBlobNotFound for a missing blob, got %v", err)
+	}
+
+	for _, bad := range []string{"ftp://example.com", "example.com", "http://"} {
+		if _, err = NewRegistryMirror(bad, false); err == nil {
+			t.Errorf("expected an error for mirror URL %q", bad)
+		}
+	}
+}
@@ -64,6 +64,10 @@ type Builder struct {
 	// FromImageID is the ID of the source image which was used to create
 	// the container, if one was used.  It should not be modified.
 	FromImageID string `json:"image-id"`
+	// FromImageDigest is the digest of the manifest of the source image
+	// which was used to create the container, if one was used.  It should
+	// not be modified.
+	FromImageDigest string `json:"image-digest,omitempty"`
 	// Config is the source image's configuration.  It should not be
 	// modified.
 	Config []byte `json:"config,omitempty"`
@@ -93,6 +97,10 @@ type Builder struct {
 	Docker docker.V2Image `json:"docker,omitempty"`
 	// DefaultMountsFilePath is the file path holding the mounts to be mounted in "host-path:container-path" format
 	DefaultMountsFilePath string `json:"defaultMountsFilePath,omitempty"`
+	// HostPolicy restricts which hosts we can download content from when
+	// adding URLs to the container, and which registries we can write
+	// images to when committing it.
+	HostPolicy *HostPolicy `json:"host-policy,omitempty"`
 }
 
 // BuilderOptions are used to initialize a new Builder.
@@ -134,6 +142,19 @@ type BuilderOptions struct {
 	SystemContext *types.SystemContext
 	// DefaultMountsFilePath is the file path holding the mounts to be mounted in "host-path:container-path" format
 	DefaultMountsFilePath string
+	// HostPolicy restricts which registries the image can be pulled from,
+	// and is kept to restrict which hosts content can later be downloaded
+	// from when adding URLs to the container.
+	HostPolicy *HostPolicy
+	// BlobFetchers is a list of alternate sources, such as caches and
+	// registry mirrors, which are asked for the image's layers and
+	// configuration, in order, before its registry is, if we end up
+	// pulling the image.
+	BlobFetchers []BlobFetcher
+	// UseDockerDaemon causes the image to be copied from the local Docker
+	// daemon, if it has a copy of it, instead of being pulled from its
+	// registry, unless PullPolicy is PullAlways.
+	UseDockerDaemon bool
 }
 
 // ImportOptions are used to initialize a Builder from an existing container
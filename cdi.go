@@ -0,0 +1,240 @@
+package buildah
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"syscall"
+
+	"github.com/ghodss/yaml"
+	"github.com/opencontainers/runtime-spec/specs-go"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"golang.org/x/sys/unix"
+)
+
+var (
+	// CDISpecDirs is the list of directories which are searched for
+	// container device interface (CDI) specifications.
+	CDISpecDirs = []string{"/etc/cdi", "/var/run/cdi"}
+	// GPUDeviceKinds is the list of CDI device kinds which are treated as
+	// GPUs.
+	GPUDeviceKinds = []string{"nvidia.com/gpu", "amd.com/gpu"}
+)
+
+// DeviceEdits are the changes which need to be made to a container's
+// configuration to give it access to a set of devices.
+type DeviceEdits struct {
+	// Env is a list of environment variables, in "name=value" form.
+	Env []string
+	// Devices is a list of device nodes to create in the container.
+	Devices []specs.LinuxDevice
+	// Mounts is a list of additional mounts, usually of libraries and
+	// tools which are needed to use the devices.
+	Mounts []specs.Mount
+}
+
+// cdiSpec is the subset of a CDI specification which we understand.
+type cdiSpec struct {
+	Version        string      `json:"cdiVersion"`
+	Kind           string      `json:"kind"`
+	Devices        []cdiDevice `json:"devices"`
+	ContainerEdits cdiEdits    `json:"containerEdits,omitempty"`
+	path           string
+}
+
+type cdiDevice struct {
+	Name           string   `json:"name"`
+	ContainerEdits cdiEdits `json:"containerEdits"`
+}
+
+type cdiEdits struct {
+	Env         []string        `json:"env,omitempty"`
+	DeviceNodes []cdiDeviceNode `json:"deviceNodes,omitempty"`
+	Mounts      []cdiMount      `json:"mounts,omitempty"`
+	Hooks       []interface{}   `json:"hooks,omitempty"`
+}
+
+type cdiDeviceNode struct {
+	Path     string `json:"path"`
+	HostPath string `json:"hostPath,omitempty"`
+	Type     string `json:"type,omitempty"`
+	Major    int64  `json:"major,omitempty"`
+	Minor    int64  `json:"minor,omitempty"`
+}
+
+type cdiMount struct {
+	HostPath      string   `json:"hostPath"`
+	ContainerPath string   `json:"containerPath"`
+	Type          string   `json:"type,omitempty"`
+	Options       []string `json:"options,omitempty"`
+}
+
+// readCDISpecs reads all of the CDI specifications in the specified
+// directories.  Specifications in later directories override those in earlier
+// ones for the same device kind.
+func readCDISpecs(dirs []string) (map[string]*cdiSpec, error) {
+	cdiSpecs := make(map[string]*cdiSpec)
+	for _, dir := range dirs {
+		entries, err := ioutil.ReadDir(dir)
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return nil, errors.Wrapf(err, "error reading CDI specification directory %q", dir)
+		}
+		for _, entry := range entries {
+			ext := filepath.Ext(entry.Name())
+			if entry.IsDir() || (ext != ".json" && ext != ".yaml" && ext != ".yml") {
+				continue
+			}
+			path := filepath.Join(dir, entry.Name())
+			data, err := ioutil.ReadFile(path)
+			if err != nil {
+				return nil, errors.Wrapf(err, "error reading CDI specification %q", path)
+			}
+			spec := cdiSpec{path: path}
+			if err = yaml.Unmarshal(data, &spec); err != nil {
+				return nil, errors.Wrapf(err, "error parsing CDI specification %q", path)
+			}
+			if spec.Kind == "" {
+				logrus.Debugf("ignoring CDI specification %q, which has no kind", path)
+				continue
+			}
+			cdiSpecs[spec.Kind] = &spec
+		}
+	}
+	return cdiSpecs, nil
+}
+
+// apply adds the edits to the list.
+func (e *cdiEdits) apply(edits *DeviceEdits, source string) error {
+	edits.Env = append(edits.Env, e.Env...)
+	for _, node := range e.DeviceNodes {
+		device, err := node.linuxDevice()
+		if err != nil {
+			return errors.Wrapf(err, "error resolving device %q from %q", node.Path, source)
+		}
+		edits.Devices = append(edits.Devices, device)
+	}
+	for _, mount := range e.Mounts {
+		mountType := mount.Type
+		if mountType == "" {
+			mountType = "bind"
+		}
+		options := mount.Options
+		if len(options) == 0 {
+			options = []string{"rbind", "ro"}
+		}
+		edits.Mounts = append(edits.Mounts, specs.Mount{
+			Source:      mount.HostPath,
+			Destination: mount.ContainerPath,
+			Type:        mountType,
+			Options:     options,
+		})
+	}
+	if len(e.Hooks) > 0 {
+		logrus.Debugf("ignoring hooks in %q", source)
+	}
+	return nil
+}
+
+// linuxDevice fills in any information about a device node which its
+// specification leaves out by examining the device on the host.
+func (n *cdiDeviceNode) linuxDevice() (specs.LinuxDevice, error) {
+	hostPath := n.HostPath
+	if hostPath == "" {
+		hostPath = n.Path
+	}
+	device := specs.LinuxDevice{
+		Path:  n.Path,
+		Type:  n.Type,
+		Major: n.Major,
+		Minor: n.Minor,
+	}
+	if device.Type == "" || (device.Major == 0 && device.Minor == 0) {
+		var st syscall.Stat_t
+		if err := syscall.Stat(hostPath, &st); err != nil {
+			return device, errors.Wrapf(err, "error examining device %q", hostPath)
+		}
+		switch st.Mode & syscall.S_IFMT {
+		case syscall.S_IFCHR:
+			device.Type = "c"
+		case syscall.S_IFBLK:
+			device.Type = "b"
+		default:
+			return device, errors.Errorf("%q is not a device", hostPath)
+		}
+		device.Major = int64(unix.Major(uint64(st.Rdev)))
+		device.Minor = int64(unix.Minor(uint64(st.Rdev)))
+	}
+	return device, nil
+}
+
+// GPUDevices uses the CDI specifications in CDISpecDirs to compute the edits
+// which give a container access to GPUs.  The request can be "all", or a
+// comma-separated list of device names, optionally qualified with the device
+// kind, as in "nvidia.com/gpu=0".
+func GPUDevices(request string) (*DeviceEdits, error) {
+	cdiSpecs, err := readCDISpecs(CDISpecDirs)
+	if err != nil {
+		return nil, err
+	}
+	kinds := []string{}
+	for _, kind := range GPUDeviceKinds {
+		if _, ok := cdiSpecs[kind]; ok {
+			kinds = append(kinds, kind)
+		}
+	}
+	if len(kinds) == 0 {
+		return nil, errors.Errorf("no CDI specifications for GPUs (%s) found in %s", strings.Join(GPUDeviceKinds, ", "), strings.Join(CDISpecDirs, ", "))
+	}
+	sort.Strings(kinds)
+	edits := &DeviceEdits{}
+	kindsApplied := make(map[string]bool)
+	devicesApplied := make(map[string]bool)
+	applyDevice := func(spec *cdiSpec, device *cdiDevice) error {
+		qualified := spec.Kind + "=" + device.Name
+		if devicesApplied[qualified] {
+			return nil
+		}
+		// Edits which apply to every device of a kind only need to be
+		// made once.
+		if !kindsApplied[spec.Kind] {
+			if err := spec.ContainerEdits.apply(edits, spec.path); err != nil {
+				return err
+			}
+			kindsApplied[spec.Kind] = true
+		}
+		devicesApplied[qualified] = true
+		return device.ContainerEdits.apply(edits, spec.path)
+	}
+	for _, name := range strings.Split(request, ",") {
+		name = strings.TrimSpace(name)
+		found := false
+		for _, kind := range kinds {
+			spec := cdiSpecs[kind]
+			devName := name
+			if strings.Contains(name, "=") {
+				if !strings.HasPrefix(name, kind+"=") {
+					continue
+				}
+				devName = strings.TrimPrefix(name, kind+"=")
+			}
+			for i := range spec.Devices {
+				if devName == "all" || spec.Devices[i].Name == devName {
+					if err = applyDevice(spec, &spec.Devices[i]); err != nil {
+						return nil, err
+					}
+					found = true
+				}
+			}
+		}
+		if !found {
+			return nil, errors.Errorf("no GPU named %q found in CDI specifications", name)
+		}
+	}
+	return edits, nil
+}
@@ -0,0 +1,68 @@
+package buildah
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+const testCDISpec = `
+cdiVersion: "0.3.0"
+kind: nvidia.com/gpu
+devices:
+- name: "0"
+  containerEdits:
+    deviceNodes:
+    - path: /dev/nvidia0
+      hostPath: /dev/null
+- name: "1"
+  containerEdits:
+    deviceNodes:
+    - path: /dev/nvidia1
+      type: c
+      major: 195
+      minor: 1
+containerEdits:
+  env:
+  - NVIDIA_VISIBLE_DEVICES=void
+  mounts:
+  - hostPath: /usr/lib64/libcuda.so.1
+    containerPath: /usr/lib64/libcuda.so.1
+`
+
+func TestGPUDevices(t *testing.T) {
+	dir, err := ioutil.TempDir("", "buildah-cdi")
+	if err != nil {
+		t.Fatalf("error creating temporary directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	if err = ioutil.WriteFile(filepath.Join(dir, "nvidia.yaml"), []byte(testCDISpec), 0644); err != nil {
+		t.Fatalf("error writing CDI specification: %v", err)
+	}
+	defer func(dirs []string) { CDISpecDirs = dirs }(CDISpecDirs)
+	CDISpecDirs = []string{dir}
+
+	edits, err := GPUDevices("all")
+	if err != nil {
+		t.Fatalf("error resolving all GPUs: %v", err)
+	}
+	if len(edits.Devices) != 2 || len(edits.Env) != 1 || len(edits.Mounts) != 1 {
+		t.Fatalf("expected 2 devices, 1 environment variable, and 1 mount, got %+v", edits)
+	}
+	if edits.Devices[0].Path != "/dev/nvidia0" || edits.Devices[0].Type != "c" || edits.Devices[0].Major != 1 || edits.Devices[0].Minor != 3 {
+		t.Errorf("expected /dev/nvidia0 to be a copy of /dev/null, got %+v", edits.Devices[0])
+	}
+
+	edits, err = GPUDevices("nvidia.com/gpu=1,1")
+	if err != nil {
+		t.Fatalf("error resolving GPU 1: %v", err)
+	}
+	if len(edits.Devices) != 1 || edits.Devices[0].Major != 195 || len(edits.Env) != 1 {
+		t.Errorf("expected GPU 1 and common edits, got %+v", edits)
+	}
+
+	if _, err = GPUDevices("2"); err == nil {
+		t.Errorf("expected a request for a GPU that isn't described to fail")
+	}
+}
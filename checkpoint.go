@@ -0,0 +1,160 @@
+package buildah
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+
+	"github.com/containers/storage/pkg/ioutils"
+	"github.com/opencontainers/runtime-spec/specs-go"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// checkpointConfigFile is the name of the file, in a checkpoint's
+	// directory, where we save the runtime configuration which was being
+	// used for the command that was checkpointed.
+	checkpointConfigFile = "buildah-config.json"
+)
+
+// CheckpointOptions can be used to alter how a running command is
+// checkpointed or restored.
+type CheckpointOptions struct {
+	// Runtime is the name of the command to run.  It should accept the
+	// same arguments that runc does, and use CRIU to checkpoint and
+	// restore processes.
+	Runtime string
+	// Args adds global arguments for the runtime.
+	Args []string
+	// ImagePath is the directory in which the checkpoint is stored.
+	ImagePath string
+	// LeaveRunning lets the command continue running after it has been
+	// checkpointed, instead of stopping it.
+	LeaveRunning bool
+	// TCPEstablished allows established TCP connections to be
+	// checkpointed and restored.
+	TCPEstablished bool
+}
+
+func (options *CheckpointOptions) runtimeCommand(args ...string) *exec.Cmd {
+	runtime := options.Runtime
+	if runtime == "" {
+		runtime = DefaultRuntime
+	}
+	return exec.Command(runtime, append(append([]string{}, options.Args...), args...)...)
+}
+
+// runtimeBundle is the subset of the output of the runtime's "state" command
+// which tells us where the running container's configuration is.
+type runtimeBundle struct {
+	Bundle string `json:"bundle"`
+}
+
+// Checkpoint uses the runtime to save the state of a command which is being
+// run in the container by Run() to options.ImagePath, so that it can be
+// resumed later using Restore(), possibly on another host which has the same
+// container in its storage.  Unless options.LeaveRunning is set, the command
+// is stopped, and the call to Run() which started it will return an error.
+func (b *Builder) Checkpoint(options CheckpointOptions) error {
+	if options.ImagePath == "" {
+		return errors.Errorf("no location specified for checkpoint of container %q", b.Container)
+	}
+	output, err := options.runtimeCommand("state", b.runtimeContainerName()).Output()
+	if err != nil {
+		return errors.Errorf("no command is currently running in container %q", b.Container)
+	}
+	state := runtimeBundle{}
+	if err = json.Unmarshal(output, &state); err != nil {
+		return errors.Wrapf(err, "error parsing state of %q", b.runtimeContainerName())
+	}
+	if err = os.MkdirAll(options.ImagePath, 0700); err != nil {
+		return errors.Wrapf(err, "error creating checkpoint directory %q", options.ImagePath)
+	}
+	// Save a copy of the configuration, since Run() will remove the
+	// bundle directory once the command exits.
+	config, err := ioutil.ReadFile(filepath.Join(state.Bundle, "config.json"))
+	if err != nil {
+		return errors.Wrapf(err, "error reading runtime configuration for %q", b.runtimeContainerName())
+	}
+	if err = ioutils.AtomicWriteFile(filepath.Join(options.ImagePath, checkpointConfigFile), config, 0600); err != nil {
+		return errors.Wrapf(err, "error saving runtime configuration for %q", b.runtimeContainerName())
+	}
+	args := []string{"checkpoint", "--image-path", options.ImagePath}
+	if options.LeaveRunning {
+		args = append(args, "--leave-running")
+	}
+	if options.TCPEstablished {
+		args = append(args, "--tcp-established")
+	}
+	cmd := options.runtimeCommand(append(args, b.runtimeContainerName())...)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err = cmd.Run(); err != nil {
+		return errors.Wrapf(err, "error checkpointing %q", b.runtimeContainerName())
+	}
+	return nil
+}
+
+// Restore uses the runtime to resume a command which was saved using
+// Checkpoint(), and waits for it to exit.
+func (b *Builder) Restore(options CheckpointOptions) error {
+	if options.ImagePath == "" {
+		return errors.Errorf("no location specified for checkpoint of container %q", b.Container)
+	}
+	config, err := ioutil.ReadFile(filepath.Join(options.ImagePath, checkpointConfigFile))
+	if err != nil {
+		return errors.Wrapf(err, "error reading runtime configuration from checkpoint %q", options.ImagePath)
+	}
+	spec := specs.Spec{}
+	if err = json.Unmarshal(config, &spec); err != nil {
+		return errors.Wrapf(err, "error parsing runtime configuration from checkpoint %q", options.ImagePath)
+	}
+	mountPoint, err := b.Mount(b.MountLabel)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err2 := b.Unmount(); err2 != nil {
+			logrus.Errorf("error unmounting container: %v", err2)
+		}
+	}()
+	// The root filesystem may be mounted somewhere else now.
+	if spec.Root == nil {
+		spec.Root = &specs.Root{}
+	}
+	spec.Root.Path = mountPoint
+	specbytes, err := json.Marshal(spec)
+	if err != nil {
+		return err
+	}
+	path, err := ioutil.TempDir(os.TempDir(), Package)
+	if err != nil {
+		return err
+	}
+	logrus.Debugf("using %q to hold bundle data", path)
+	defer func() {
+		if err2 := os.RemoveAll(path); err2 != nil {
+			logrus.Errorf("error removing %q: %v", path, err2)
+		}
+	}()
+	if err = ioutils.AtomicWriteFile(filepath.Join(path, "config.json"), specbytes, 0600); err != nil {
+		return errors.Wrapf(err, "error storing runtime configuration")
+	}
+	args := []string{"restore", "--image-path", options.ImagePath, "--bundle", path}
+	if options.TCPEstablished {
+		args = append(args, "--tcp-established")
+	}
+	cmd := options.runtimeCommand(append(args, b.runtimeContainerName())...)
+	cmd.Dir = mountPoint
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	err = cmd.Run()
+	if err != nil {
+		logrus.Debugf("error restoring %q: %v", b.runtimeContainerName(), err)
+	}
+	return err
+}
@@ -1,6 +1,9 @@
 package main
 
 import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
 	"os"
 	"path/filepath"
 	"strings"
@@ -17,6 +20,18 @@ var (
 			Name:  "authfile",
 			Usage: "path of the authentication file. Default is ${XDG_RUNTIME_DIR}/containers/auth.json",
 		},
+		cli.StringFlag{
+			Name:  "auto-tag-from-label",
+			Usage: "add major, major.minor, and latest tags based on the semantic version in `label`",
+		},
+		cli.StringFlag{
+			Name:  "blob-cache",
+			Usage: "`directory` in which to keep copies of the layers of pulled images, for reuse by later pulls",
+		},
+		cli.StringSliceFlag{
+			Name:  "blob-mirror",
+			Usage: "`URL` of a registry mirror or peer-to-peer agent to try reading layers from before the image's registry",
+		},
 		cli.StringSliceFlag{
 			Name:  "build-arg",
 			Usage: "`argument=value` to supply to the builder",
@@ -29,6 +44,46 @@ var (
 			Name:  "format",
 			Usage: "`format` of the built image's manifest and metadata",
 		},
+		cli.StringFlag{
+			Name:  "gpus",
+			Usage: "make GPUs described by CDI specifications available: `all` or a comma-separated list of device names",
+		},
+		cli.StringSliceFlag{
+			Name:  "host-engine",
+			Usage: "reuse images or layers which `engine` (docker, or containerd[=content-store-path]) already has, instead of pulling them",
+		},
+		cli.StringFlag{
+			Name:  "host-policy",
+			Usage: "`pathname` of a JSON file listing registries and hosts which may be contacted",
+		},
+		cli.BoolFlag{
+			Name:  "init",
+			Usage: "run an init process as PID 1 which forwards signals and reaps zombie processes",
+		},
+		cli.StringFlag{
+			Name:  "init-path",
+			Usage: "`path` to the init binary to use with --init",
+		},
+		cli.BoolFlag{
+			Name:  "keep-stages",
+			Usage: "keep the result of each stage in local storage as `NAME/stage:N`",
+		},
+		cli.StringFlag{
+			Name:  "network",
+			Usage: "`mode` for RUN instructions' network access: \"default\", or \"none\" to only allow it for those which specify --network=default",
+		},
+		cli.StringSliceFlag{
+			Name:  "notify",
+			Usage: "send build start, success, and failure events to `target`, an http or https URL or the path of a file or named pipe",
+		},
+		cli.BoolFlag{
+			Name:  "plan",
+			Usage: "print the stages and steps of the build, with their cache keys, as JSON, instead of building",
+		},
+		cli.StringFlag{
+			Name:  "progress",
+			Usage: "`type` of progress output: \"plain\", or \"ci\" for collapsible groups and annotations understood by GitHub Actions and GitLab CI",
+		},
 		cli.BoolTFlag{
 			Name:  "pull",
 			Usage: "pull the image if not present",
@@ -50,10 +105,18 @@ var (
 			Name:  "runtime-flag",
 			Usage: "add global flags for the container runtime",
 		},
+		cli.StringSliceFlag{
+			Name:  "secret",
+			Usage: "make a secret available to RUN instructions, specified as `id=ID,src=PATH` or `id=ID,env=VARIABLE`",
+		},
 		cli.StringFlag{
 			Name:  "signature-policy",
 			Usage: "`pathname` of signature policy file (not usually used)",
 		},
+		cli.BoolFlag{
+			Name:  "strict",
+			Usage: "treat build warnings as errors",
+		},
 		cli.StringSliceFlag{
 			Name:  "tag, t",
 			Usage: "`tag` to apply to the built image",
@@ -62,6 +125,10 @@ var (
 			Name:  "tls-verify",
 			Usage: "require HTTPS and verify certificates when accessing the registry",
 		},
+		cli.BoolFlag{
+			Name:  "vcs-labels",
+			Usage: "record the git revision and repository of the build context in labels and annotations",
+		},
 	}
 
 	budDescription = "Builds an OCI image using instructions in one or more Dockerfiles."
@@ -106,6 +173,15 @@ func budCmd(c *cli.Context) error {
 		}
 	}
 
+	secrets, err := parseSecrets(c.StringSlice("secret"))
+	if err != nil {
+		return err
+	}
+	hostPolicy, err := hostPolicyFromOptions(c)
+	if err != nil {
+		return err
+	}
+
 	dockerfiles := c.StringSlice("file")
 	format := "oci"
 	if c.IsSet("format") {
@@ -176,29 +252,117 @@ func budCmd(c *cli.Context) error {
 		return err
 	}
 
-	store, err := getStore(c)
+	options := imagebuildah.BuildOptions{
+		ContextDirectory:      contextDir,
+		PullPolicy:            pullPolicy,
+		Compression:           imagebuildah.Gzip,
+		Quiet:                 c.Bool("quiet"),
+		Progress:              c.String("progress"),
+		SignaturePolicyPath:   c.String("signature-policy"),
+		SkipTLSVerify:         !c.Bool("tls-verify"),
+		Args:                  args,
+		Output:                output,
+		AdditionalTags:        tags,
+		KeepStages:            c.Bool("keep-stages"),
+		AutoTagFromLabel:      c.String("auto-tag-from-label"),
+		Runtime:               c.String("runtime"),
+		RuntimeArgs:           c.StringSlice("runtime-flag"),
+		OutputFormat:          format,
+		AuthFilePath:          c.String("authfile"),
+		Strict:                c.Bool("strict"),
+		Secrets:               secrets,
+		Network:               c.String("network"),
+		HostPolicy:            hostPolicy,
+		DefaultMountsFilePath: c.GlobalString("default-mounts-file"),
+		GPUs:                  c.String("gpus"),
+		Init:                  c.Bool("init"),
+		InitPath:              c.String("init-path"),
+		Notify:                c.StringSlice("notify"),
+		VCSLabels:             c.Bool("vcs-labels"),
+	}
+	if !c.Bool("quiet") {
+		options.ReportWriter = os.Stderr
+	}
+
+	if c.Bool("plan") {
+		plan, err := imagebuildah.PlanBuild(options, dockerfiles...)
+		if err != nil {
+			return err
+		}
+		b, err := json.MarshalIndent(plan, "", "    ")
+		if err != nil {
+			return errors.Wrapf(err, "error encoding build plan as json")
+		}
+		_, err = fmt.Println(string(b))
+		return err
+	}
+
+	options.UseDockerDaemon = useDockerDaemon(c)
+	options.BlobFetchers, err = blobFetchersFromOptions(c, hostPolicy)
 	if err != nil {
 		return err
 	}
 
-	options := imagebuildah.BuildOptions{
-		ContextDirectory:    contextDir,
-		PullPolicy:          pullPolicy,
-		Compression:         imagebuildah.Gzip,
-		Quiet:               c.Bool("quiet"),
-		SignaturePolicyPath: c.String("signature-policy"),
-		SkipTLSVerify:       !c.Bool("tls-verify"),
-		Args:                args,
-		Output:              output,
-		AdditionalTags:      tags,
-		Runtime:             c.String("runtime"),
-		RuntimeArgs:         c.StringSlice("runtime-flag"),
-		OutputFormat:        format,
-		AuthFilePath:        c.String("authfile"),
+	store, err := getStore(c)
+	if err != nil {
+		return err
 	}
-	if !c.Bool("quiet") {
-		options.ReportWriter = os.Stderr
+
+	warnings, err := imagebuildah.BuildDockerfiles(store, options, dockerfiles...)
+	for _, warning := range warnings {
+		fmt.Fprintf(os.Stderr, "WARNING: %s\n", warning)
 	}
+	return err
+}
 
-	return imagebuildah.BuildDockerfiles(store, options, dockerfiles...)
+// parseSecrets reads the values of secrets, each of which is specified as a
+// comma-separated list of key=value pairs, where the "id" key is required,
+// and one of "src" or "env" names the file or environment variable which
+// holds the secret's value.
+func parseSecrets(specs []string) (map[string]string, error) {
+	secrets := make(map[string]string)
+	for _, spec := range specs {
+		var id, src, env string
+		for _, field := range strings.Split(spec, ",") {
+			kv := strings.SplitN(field, "=", 2)
+			if len(kv) != 2 {
+				return nil, errors.Errorf("error parsing secret %q: expected key=value, got %q", spec, field)
+			}
+			switch kv[0] {
+			case "id":
+				id = kv[1]
+			case "src", "source":
+				src = kv[1]
+			case "env":
+				env = kv[1]
+			default:
+				return nil, errors.Errorf("error parsing secret %q: unrecognized key %q", spec, kv[0])
+			}
+		}
+		if id == "" {
+			return nil, errors.Errorf("error parsing secret %q: no id specified", spec)
+		}
+		if _, ok := secrets[id]; ok {
+			return nil, errors.Errorf("secret %q specified more than once", id)
+		}
+		switch {
+		case src != "" && env != "":
+			return nil, errors.Errorf("error parsing secret %q: only one of src and env may be specified", spec)
+		case src != "":
+			contents, err := ioutil.ReadFile(src)
+			if err != nil {
+				return nil, errors.Wrapf(err, "error reading secret %q from %q", id, src)
+			}
+			secrets[id] = string(contents)
+		case env != "":
+			value, ok := os.LookupEnv(env)
+			if !ok {
+				return nil, errors.Errorf("environment variable %q for secret %q is not set", env, id)
+			}
+			secrets[id] = value
+		default:
+			return nil, errors.Errorf("error parsing secret %q: one of src or env must be specified", spec)
+		}
+	}
+	return secrets, nil
 }
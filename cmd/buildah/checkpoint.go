@@ -0,0 +1,125 @@
+package main
+
+import (
+	"os"
+	"os/exec"
+	"syscall"
+
+	"github.com/pkg/errors"
+	"github.com/projectatomic/buildah"
+	"github.com/urfave/cli"
+)
+
+var (
+	checkpointFlags = []cli.Flag{
+		cli.BoolFlag{
+			Name:  "leave-running",
+			Usage: "leave the command running after checkpointing it",
+		},
+		cli.StringFlag{
+			Name:  "runtime",
+			Usage: "`path` to an alternate runtime",
+			Value: buildah.DefaultRuntime,
+		},
+		cli.StringSliceFlag{
+			Name:  "runtime-flag",
+			Usage: "add global flags for the container runtime",
+		},
+		cli.BoolFlag{
+			Name:  "tcp-established",
+			Usage: "checkpoint established TCP connections",
+		},
+	}
+	checkpointDescription = "Saves the state of a command which is currently being run in the container\n   to a directory, using CRIU, so that it can be restored later"
+	checkpointCommand     = cli.Command{
+		Name:        "checkpoint",
+		Usage:       "Checkpoint a command which is running in a container",
+		Description: checkpointDescription,
+		Flags:       checkpointFlags,
+		Action:      checkpointCmd,
+		ArgsUsage:   "CONTAINER-NAME-OR-ID DIRECTORY",
+	}
+
+	restoreFlags = []cli.Flag{
+		cli.StringFlag{
+			Name:  "runtime",
+			Usage: "`path` to an alternate runtime",
+			Value: buildah.DefaultRuntime,
+		},
+		cli.StringSliceFlag{
+			Name:  "runtime-flag",
+			Usage: "add global flags for the container runtime",
+		},
+		cli.BoolFlag{
+			Name:  "tcp-established",
+			Usage: "restore established TCP connections",
+		},
+	}
+	restoreDescription = "Resumes a command which was checkpointed using the checkpoint command, and\n   waits for it to exit"
+	restoreCommand     = cli.Command{
+		Name:        "restore",
+		Usage:       "Restore a command which was checkpointed",
+		Description: restoreDescription,
+		Flags:       restoreFlags,
+		Action:      restoreCmd,
+		ArgsUsage:   "CONTAINER-NAME-OR-ID DIRECTORY",
+	}
+)
+
+func checkpointOptions(c *cli.Context, flags []cli.Flag) (*buildah.Builder, buildah.CheckpointOptions, error) {
+	args := c.Args()
+	if len(args) == 0 {
+		return nil, buildah.CheckpointOptions{}, errors.Errorf("container ID must be specified")
+	}
+	if len(args) == 1 {
+		return nil, buildah.CheckpointOptions{}, errors.Errorf("checkpoint directory must be specified")
+	}
+	if len(args) > 2 {
+		return nil, buildah.CheckpointOptions{}, errors.Errorf("too many arguments specified")
+	}
+	name := args[0]
+	if err := validateFlags(c, flags); err != nil {
+		return nil, buildah.CheckpointOptions{}, err
+	}
+
+	store, err := getStore(c)
+	if err != nil {
+		return nil, buildah.CheckpointOptions{}, err
+	}
+
+	builder, err := openBuilder(store, name)
+	if err != nil {
+		return nil, buildah.CheckpointOptions{}, errors.Wrapf(err, "error reading build container %q", name)
+	}
+
+	options := buildah.CheckpointOptions{
+		Runtime:        c.String("runtime"),
+		Args:           c.StringSlice("runtime-flag"),
+		ImagePath:      args[1],
+		LeaveRunning:   c.Bool("leave-running"),
+		TCPEstablished: c.Bool("tcp-established"),
+	}
+	return builder, options, nil
+}
+
+func checkpointCmd(c *cli.Context) error {
+	builder, options, err := checkpointOptions(c, checkpointFlags)
+	if err != nil {
+		return err
+	}
+	return builder.Checkpoint(options)
+}
+
+func restoreCmd(c *cli.Context) error {
+	builder, options, err := checkpointOptions(c, restoreFlags)
+	if err != nil {
+		return err
+	}
+	err = builder.Restore(options)
+	if ee, ok := err.(*exec.ExitError); ok {
+		if w, ok := ee.Sys().(syscall.WaitStatus); ok {
+			os.Exit(w.ExitStatus())
+		}
+	}
+	return err
+}
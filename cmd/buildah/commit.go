@@ -10,11 +10,20 @@ import (
 	"github.com/containers/storage/pkg/archive"
 	"github.com/pkg/errors"
 	"github.com/projectatomic/buildah"
+	"github.com/sirupsen/logrus"
 	"github.com/urfave/cli"
 )
 
 var (
 	commitFlags = []cli.Flag{
+		cli.StringFlag{
+			Name:  "auto-tag-from-label",
+			Usage: "add major, major.minor, and latest tags based on the semantic version in `label`",
+		},
+		cli.StringFlag{
+			Name:  "base-image",
+			Usage: "record `image` as the new image's base, in place of the image that the container was created from",
+		},
 		cli.StringFlag{
 			Name:  "cert-dir",
 			Value: "",
@@ -55,6 +64,10 @@ var (
 			Name:  "tls-verify",
 			Usage: "Require HTTPS and verify certificates when accessing the registry",
 		},
+		cli.BoolFlag{
+			Name:  "vcs-labels",
+			Usage: "record the git revision and repository of the current directory in labels and annotations",
+		},
 	}
 	commitDescription = "Writes a new image using the container's read-write layer and, if it is based\n   on an image, the layers of that image"
 	commitCommand     = cli.Command{
@@ -137,10 +150,24 @@ func commitCmd(c *cli.Context) error {
 		SignaturePolicyPath:   c.String("signature-policy"),
 		HistoryTimestamp:      &timestamp,
 		SystemContext:         systemContext,
+		AutoTagFromLabel:      c.String("auto-tag-from-label"),
+		BaseImage:             c.String("base-image"),
 	}
 	if !c.Bool("quiet") {
 		options.ReportWriter = os.Stderr
 	}
+	if c.Bool("vcs-labels") {
+		info, err := buildah.DetectVCS(".")
+		if err != nil {
+			return err
+		}
+		if info == nil {
+			logrus.Warnf("the current directory is not part of a git checkout, not adding revision labels")
+		} else if info.Dirty {
+			logrus.Warnf("the git checkout has uncommitted changes, which revision %s does not include", info.Revision)
+		}
+		builder.SetVCSLabels(info)
+	}
 	err = builder.Commit(dest, options)
 	if err != nil {
 		return errors.Wrapf(err, "error committing container %q to %q", builder.Container, image)
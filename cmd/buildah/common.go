@@ -2,6 +2,7 @@ package main
 
 import (
 	"os"
+	"path/filepath"
 	"reflect"
 	"regexp"
 	"strings"
@@ -14,12 +15,35 @@ import (
 	digest "github.com/opencontainers/go-digest"
 	"github.com/pkg/errors"
 	"github.com/projectatomic/buildah"
+	"github.com/projectatomic/buildah/util"
 	"github.com/urfave/cli"
 )
 
 var needToShutdownStore = false
 
+// ephemeralStore and removeEphemeralStore are set if $BUILDAH_STORE asked us to
+// use a throwaway store, which needs to be removed when we exit.
+var (
+	ephemeralStore       storage.Store
+	removeEphemeralStore func() error
+)
+
 func getStore(c *cli.Context) (storage.Store, error) {
+	ephemeral, err := util.UseEphemeralStore()
+	if err != nil {
+		return nil, err
+	}
+	if ephemeral {
+		if ephemeralStore == nil {
+			store, remove, err := util.NewEphemeralStore()
+			if err != nil {
+				return nil, err
+			}
+			ephemeralStore, removeEphemeralStore = store, remove
+			is.Transport.SetStore(store)
+		}
+		return ephemeralStore, nil
+	}
 	options := storage.DefaultStoreOptions
 	if c.GlobalIsSet("root") || c.GlobalIsSet("runroot") {
 		options.GraphRoot = c.GlobalString("root")
@@ -34,6 +58,13 @@ func getStore(c *cli.Context) (storage.Store, error) {
 			options.GraphDriverOptions = opts
 		}
 	}
+	if c.GlobalIsSet("image-store") {
+		driver, opts, err := addImageStoreOptions(options.GraphDriverName, options.GraphDriverOptions, c.GlobalStringSlice("image-store"))
+		if err != nil {
+			return nil, err
+		}
+		options.GraphDriverName, options.GraphDriverOptions = driver, opts
+	}
 	store, err := storage.GetStore(options)
 	if store != nil {
 		is.Transport.SetStore(store)
@@ -42,6 +73,31 @@ func getStore(c *cli.Context) (storage.Store, error) {
 	return store, err
 }
 
+// addImageStoreOptions adds the storage driver options which make it use
+// additional, read-only image stores.  Only the overlay driver supports them,
+// so it's selected if no driver was specified.
+func addImageStoreOptions(driver string, options, stores []string) (string, []string, error) {
+	switch driver {
+	case "":
+		driver = "overlay"
+	case "overlay", "overlay2":
+	default:
+		return "", nil, errors.Errorf("additional image stores are not supported by the %q storage driver, only by \"overlay\"", driver)
+	}
+	options = append([]string{}, options...)
+	for _, store := range stores {
+		if !filepath.IsAbs(store) {
+			abs, err := filepath.Abs(store)
+			if err != nil {
+				return "", nil, errors.Wrapf(err, "error finding absolute path of image store %q", store)
+			}
+			store = abs
+		}
+		options = append(options, driver+".imagestore="+store)
+	}
+	return driver, options, nil
+}
+
 func openBuilder(store storage.Store, name string) (builder *buildah.Builder, err error) {
 	if name != "" {
 		builder, err = buildah.OpenBuilder(store, name)
@@ -139,6 +195,70 @@ func systemContextFromOptions(c *cli.Context) (*types.SystemContext, error) {
 	return ctx, nil
 }
 
+func hostPolicyFromOptions(c *cli.Context) (*buildah.HostPolicy, error) {
+	if !c.IsSet("host-policy") {
+		return nil, nil
+	}
+	return buildah.LoadHostPolicy(c.String("host-policy"))
+}
+
+// blobFetchersFromOptions returns the alternate sources for blobs which were
+// specified using the --host-engine, --blob-cache, and --blob-mirror flags,
+// with the ones on this host first, so that they're used if they have a blob,
+// and so that the cache saves blobs which are read from the mirrors.
+func blobFetchersFromOptions(c *cli.Context, hostPolicy *buildah.HostPolicy) ([]buildah.BlobFetcher, error) {
+	var fetchers []buildah.BlobFetcher
+	for _, engine := range c.StringSlice("host-engine") {
+		name, root := engine, ""
+		if i := strings.Index(engine, "="); i >= 0 {
+			name, root = engine[:i], engine[i+1:]
+		}
+		switch name {
+		case "docker":
+			if root != "" {
+				return nil, errors.Errorf("invalid host engine %q: the Docker daemon's location is set using $DOCKER_HOST", engine)
+			}
+		case "containerd":
+			store, err := buildah.NewContainerdContentStore(root)
+			if err != nil {
+				return nil, err
+			}
+			fetchers = append(fetchers, store)
+		default:
+			return nil, errors.Errorf("unsupported host engine %q: expected \"docker\" or \"containerd\"", engine)
+		}
+	}
+	if c.IsSet("blob-cache") {
+		cache, err := buildah.NewBlobCache(c.String("blob-cache"))
+		if err != nil {
+			return nil, err
+		}
+		fetchers = append(fetchers, cache)
+	}
+	insecure := c.IsSet("tls-verify") && !c.BoolT("tls-verify")
+	for _, mirror := range c.StringSlice("blob-mirror") {
+		m, err := buildah.NewRegistryMirror(mirror, insecure)
+		if err != nil {
+			return nil, err
+		}
+		if err = hostPolicy.Check("pull", mirror, m.Host()); err != nil {
+			return nil, err
+		}
+		fetchers = append(fetchers, m)
+	}
+	return fetchers, nil
+}
+
+// useDockerDaemon returns true if "--host-engine docker" was specified.
+func useDockerDaemon(c *cli.Context) bool {
+	for _, engine := range c.StringSlice("host-engine") {
+		if engine == "docker" {
+			return true
+		}
+	}
+	return false
+}
+
 func parseCreds(creds string) (string, string, error) {
 	if creds == "" {
 		return "", "", errors.Wrapf(syscall.EINVAL, "credentials can't be empty")
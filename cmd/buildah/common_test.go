@@ -4,6 +4,7 @@ import (
 	"flag"
 	"os"
 	"os/user"
+	"reflect"
 	"testing"
 
 	is "github.com/containers/image/storage"
@@ -91,6 +92,23 @@ func TestGetSize(t *testing.T) {
 	}
 }
 
+func TestAddImageStoreOptions(t *testing.T) {
+	driver, options, err := addImageStoreOptions("", []string{"overlay.size=10G"}, []string{"/var/lib/shared", "/mnt/images"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{"overlay.size=10G", "overlay.imagestore=/var/lib/shared", "overlay.imagestore=/mnt/images"}
+	if driver != "overlay" || !reflect.DeepEqual(options, expected) {
+		t.Errorf("expected driver %q with options %v, got %q with %v", "overlay", expected, driver, options)
+	}
+	if driver, options, err = addImageStoreOptions("overlay2", nil, []string{"/var/lib/shared"}); err != nil || driver != "overlay2" || !reflect.DeepEqual(options, []string{"overlay2.imagestore=/var/lib/shared"}) {
+		t.Errorf("unexpected results for overlay2: %q, %v, %v", driver, options, err)
+	}
+	if _, _, err = addImageStoreOptions("vfs", nil, []string{"/var/lib/shared"}); err == nil {
+		t.Errorf("expected an error using an image store with the vfs driver")
+	}
+}
+
 func failTestIfNotRoot(t *testing.T) {
 	u, err := user.Current()
 	if err != nil {
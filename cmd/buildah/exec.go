@@ -0,0 +1,102 @@
+package main
+
+import (
+	"os"
+	"os/exec"
+	"syscall"
+
+	"github.com/pkg/errors"
+	"github.com/projectatomic/buildah"
+	"github.com/sirupsen/logrus"
+	"github.com/urfave/cli"
+)
+
+var (
+	execFlags = []cli.Flag{
+		cli.StringFlag{
+			Name:  "runtime",
+			Usage: "`path` to an alternate runtime",
+			Value: buildah.DefaultRuntime,
+		},
+		cli.StringSliceFlag{
+			Name:  "runtime-flag",
+			Usage: "add global flags for the container runtime",
+		},
+		cli.BoolFlag{
+			Name:  "tty",
+			Usage: "allocate a pseudo-TTY for the command",
+		},
+		cli.StringFlag{
+			Name:  "user, u",
+			Usage: "run the command as `user[:group]`",
+		},
+		cli.StringFlag{
+			Name:  "workingdir",
+			Usage: "run the command in `directory`",
+		},
+	}
+	execDescription = "Runs an additional command alongside a command which is currently being run\n   in the container, for example by a RUN instruction during a build, sharing its\n   namespaces.  If no command is specified, /bin/sh is started"
+	execCommand     = cli.Command{
+		Name:        "exec",
+		Aliases:     []string{"attach"},
+		Usage:       "Run an additional command in a container while it is running a command",
+		Description: execDescription,
+		Flags:       execFlags,
+		Action:      execCmd,
+		ArgsUsage:   "CONTAINER-NAME-OR-ID [COMMAND [ARGS [...]]]",
+	}
+)
+
+func execCmd(c *cli.Context) error {
+	args := c.Args()
+	if len(args) == 0 {
+		return errors.Errorf("container ID must be specified")
+	}
+	name := args[0]
+	if err := validateFlags(c, execFlags); err != nil {
+		return err
+	}
+
+	args = args.Tail()
+	if len(args) > 0 && args[0] == "--" {
+		args = args[1:]
+	}
+	if len(args) == 0 {
+		args = []string{"/bin/sh"}
+	}
+
+	store, err := getStore(c)
+	if err != nil {
+		return err
+	}
+
+	builder, err := openBuilder(store, name)
+	if err != nil {
+		return errors.Wrapf(err, "error reading build container %q", name)
+	}
+
+	options := buildah.RunOptions{
+		Runtime:    c.String("runtime"),
+		Args:       c.StringSlice("runtime-flag"),
+		User:       c.String("user"),
+		WorkingDir: c.String("workingdir"),
+	}
+	if c.IsSet("tty") {
+		if c.Bool("tty") {
+			options.Terminal = buildah.WithTerminal
+		} else {
+			options.Terminal = buildah.WithoutTerminal
+		}
+	}
+
+	execerr := builder.Exec(args, options)
+	if execerr != nil {
+		logrus.Debugf("error running %v in container %q: %v", args, builder.Container, execerr)
+	}
+	if ee, ok := execerr.(*exec.ExitError); ok {
+		if w, ok := ee.Sys().(syscall.WaitStatus); ok {
+			os.Exit(w.ExitStatus())
+		}
+	}
+	return execerr
+}
@@ -15,6 +15,14 @@ var (
 			Name:  "authfile",
 			Usage: "path of the authentication file. Default is ${XDG_RUNTIME_DIR}/containers/auth.json",
 		},
+		cli.StringFlag{
+			Name:  "blob-cache",
+			Usage: "`directory` in which to keep copies of the layers of pulled images, for reuse by later pulls",
+		},
+		cli.StringSliceFlag{
+			Name:  "blob-mirror",
+			Usage: "`URL` of a registry mirror or peer-to-peer agent to try reading layers from before the image's registry",
+		},
 		cli.StringFlag{
 			Name:  "cert-dir",
 			Value: "",
@@ -25,6 +33,14 @@ var (
 			Value: "",
 			Usage: "use `username[:password]` for accessing the registry",
 		},
+		cli.StringSliceFlag{
+			Name:  "host-engine",
+			Usage: "reuse images or layers which `engine` (docker, or containerd[=content-store-path]) already has, instead of pulling them",
+		},
+		cli.StringFlag{
+			Name:  "host-policy",
+			Usage: "`pathname` of a JSON file listing registries and hosts which may be contacted",
+		},
 		cli.StringFlag{
 			Name:  "name",
 			Usage: "`name` for the working container",
@@ -79,6 +95,14 @@ func fromCmd(c *cli.Context) error {
 	if err != nil {
 		return errors.Wrapf(err, "error building system context")
 	}
+	hostPolicy, err := hostPolicyFromOptions(c)
+	if err != nil {
+		return err
+	}
+	blobFetchers, err := blobFetchersFromOptions(c, hostPolicy)
+	if err != nil {
+		return err
+	}
 
 	pullPolicy := buildah.PullNever
 	if c.BoolT("pull") {
@@ -102,6 +126,9 @@ func fromCmd(c *cli.Context) error {
 		SignaturePolicyPath:   signaturePolicy,
 		SystemContext:         systemContext,
 		DefaultMountsFilePath: c.GlobalString("default-mounts-file"),
+		HostPolicy:            hostPolicy,
+		BlobFetchers:          blobFetchers,
+		UseDockerDaemon:       useDockerDaemon(c),
 	}
 	if !c.Bool("quiet") {
 		options.ReportWriter = os.Stderr
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/projectatomic/buildah"
+	"github.com/urfave/cli"
+)
+
+var (
+	imageTreeFlags = []cli.Flag{
+		cli.BoolFlag{
+			Name:  "json",
+			Usage: "output in JSON format",
+		},
+		cli.BoolFlag{
+			Name:  "no-trunc, notruncate",
+			Usage: "do not truncate output",
+		},
+	}
+	imageTreeDescription = "Shows which locally stored images, and which working containers,\n   were built from which locally stored images.  If images are specified, only\n   they and the images and containers which are based on them are shown."
+	imageTreeCommand     = cli.Command{
+		Name:        "image-tree",
+		Usage:       "Show which images in local storage are based on which",
+		Description: imageTreeDescription,
+		Flags:       imageTreeFlags,
+		Action:      imageTreeCmd,
+		ArgsUsage:   "[IMAGE-NAME-OR-ID [...]]",
+	}
+)
+
+func imageTreeCmd(c *cli.Context) error {
+	if err := validateFlags(c, imageTreeFlags); err != nil {
+		return err
+	}
+	store, err := getStore(c)
+	if err != nil {
+		return err
+	}
+	usages, err := buildah.ImageUsages(store)
+	if err != nil {
+		return err
+	}
+
+	roots := []string{}
+	if len(c.Args()) > 0 {
+		for _, name := range c.Args() {
+			image, err := getImage(name, store)
+			if err != nil {
+				return errors.Wrapf(err, "could not get image %q", name)
+			}
+			roots = append(roots, image.ID)
+		}
+	} else {
+		for id, usage := range usages {
+			if usage.Parent == "" {
+				roots = append(roots, id)
+			}
+		}
+		sortImageIDs(usages, roots)
+	}
+
+	if c.Bool("json") {
+		list := []*buildah.ImageUsage{}
+		seen := make(map[string]bool)
+		for _, root := range roots {
+			for _, id := range append([]string{root}, buildah.ImageDescendants(usages, root)...) {
+				if !seen[id] {
+					seen[id] = true
+					list = append(list, usages[id])
+				}
+			}
+		}
+		data, err := json.MarshalIndent(list, "", "    ")
+		if err != nil {
+			return err
+		}
+		fmt.Printf("%s\n", data)
+		return nil
+	}
+
+	truncate := !c.Bool("no-trunc")
+	for _, root := range roots {
+		outputImageTree(usages, root, "", "", truncate)
+	}
+	return nil
+}
+
+// sortImageIDs sorts a list of image IDs by the images' names, with images
+// that have no names sorted by ID after the ones that do.
+func sortImageIDs(usages map[string]*buildah.ImageUsage, ids []string) {
+	key := func(id string) string {
+		if usage, ok := usages[id]; ok && len(usage.Names) > 0 {
+			return "0" + usage.Names[0]
+		}
+		return "1" + id
+	}
+	sort.Slice(ids, func(i, j int) bool {
+		return key(ids[i]) < key(ids[j])
+	})
+}
+
+// outputImageTree prints an image, and then the containers and images which
+// are based on it, indented below it.
+func outputImageTree(usages map[string]*buildah.ImageUsage, id, prefix, childPrefix string, truncate bool) {
+	usage := usages[id]
+	names := "<none>"
+	if len(usage.Names) > 0 {
+		names = strings.Join(usage.Names, ", ")
+	}
+	if truncate && len(id) > 12 {
+		id = id[:12]
+	}
+	fmt.Printf("%s%s %s\n", prefix, id, names)
+	children := append([]string{}, usage.Children...)
+	sortImageIDs(usages, children)
+	count := len(usage.Containers) + len(children)
+	for i, container := range usage.Containers {
+		branch := "├── "
+		if i == count-1 {
+			branch = "└── "
+		}
+		fmt.Printf("%s%scontainer %s\n", childPrefix, branch, container)
+	}
+	for i, child := range children {
+		branch, indent := "├── ", "│   "
+		if len(usage.Containers)+i == count-1 {
+			branch, indent = "└── ", "    "
+		}
+		outputImageTree(usages, child, childPrefix+branch, childPrefix+indent, truncate)
+	}
+}
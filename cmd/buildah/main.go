@@ -53,6 +53,10 @@ func main() {
 			Usage: "storage driver option",
 			Value: defaultStoreDriverOptions,
 		},
+		cli.StringSliceFlag{
+			Name:  "image-store",
+			Usage: "`path` of an additional, read-only image store to use images and layers from",
+		},
 		cli.StringFlag{
 			Name:  "default-mounts-file",
 			Usage: "path to default mounts file",
@@ -68,6 +72,9 @@ func main() {
 		return nil
 	}
 	app.After = func(c *cli.Context) error {
+		if removeEphemeralStore != nil {
+			return removeEphemeralStore()
+		}
 		if needToShutdownStore {
 			store, err := getStore(c)
 			if err != nil {
@@ -80,21 +87,26 @@ func main() {
 	app.Commands = []cli.Command{
 		addCommand,
 		budCommand,
+		checkpointCommand,
 		commitCommand,
 		configCommand,
 		containersCommand,
 		copyCommand,
+		execCommand,
 		fromCommand,
+		imageTreeCommand,
 		imagesCommand,
 		inspectCommand,
 		mountCommand,
 		pushCommand,
+		restoreCommand,
 		rmCommand,
 		rmiCommand,
 		runCommand,
 		tagCommand,
 		umountCommand,
 		versionCommand,
+		watchBaseCommand,
 	}
 	err := app.Run(os.Args)
 	if err != nil {
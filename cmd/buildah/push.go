@@ -39,6 +39,10 @@ var (
 			Name:  "format, f",
 			Usage: "manifest type (oci, v2s1, or v2s2) to use when saving image using the 'dir:' transport (default is manifest type of source)",
 		},
+		cli.StringFlag{
+			Name:  "host-policy",
+			Usage: "`pathname` of a JSON file listing registries and hosts which may be contacted",
+		},
 		cli.BoolFlag{
 			Name:  "quiet, q",
 			Usage: "don't output progress information when pushing images",
@@ -113,6 +117,10 @@ func pushCmd(c *cli.Context) error {
 	if err != nil {
 		return errors.Wrapf(err, "error building system context")
 	}
+	hostPolicy, err := hostPolicyFromOptions(c)
+	if err != nil {
+		return err
+	}
 
 	var manifestType string
 	if c.IsSet("format") {
@@ -134,6 +142,7 @@ func pushCmd(c *cli.Context) error {
 		SignaturePolicyPath: c.String("signature-policy"),
 		Store:               store,
 		SystemContext:       systemContext,
+		HostPolicy:          hostPolicy,
 	}
 	if !c.Bool("quiet") {
 		options.ReportWriter = os.Stderr
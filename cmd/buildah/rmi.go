@@ -9,6 +9,7 @@ import (
 	"github.com/containers/image/types"
 	"github.com/containers/storage"
 	"github.com/pkg/errors"
+	"github.com/projectatomic/buildah/util"
 	"github.com/sirupsen/logrus"
 	"github.com/urfave/cli"
 )
@@ -125,6 +126,15 @@ func getImage(id string, store storage.Store) (*storage.Image, error) {
 }
 
 func untagImage(imgArg string, image *storage.Image, store storage.Store) (string, error) {
+	unlock, err := util.LockStore(store)
+	if err != nil {
+		return "", err
+	}
+	defer unlock()
+	id := image.ID
+	if image, err = store.Image(id); err != nil {
+		return "", errors.Wrapf(err, "error reading image %q", id)
+	}
 	newNames := []string{}
 	removedName := ""
 	for _, name := range image.Names {
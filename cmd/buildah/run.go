@@ -3,6 +3,7 @@ package main
 import (
 	"os"
 	"os/exec"
+	"path/filepath"
 	"strings"
 	"syscall"
 
@@ -15,10 +16,22 @@ import (
 
 var (
 	runFlags = []cli.Flag{
+		cli.StringFlag{
+			Name:  "gpus",
+			Usage: "make GPUs described by CDI specifications available: `all` or a comma-separated list of device names",
+		},
 		cli.StringFlag{
 			Name:  "hostname",
 			Usage: "Set the hostname inside of the container",
 		},
+		cli.BoolFlag{
+			Name:  "init",
+			Usage: "run an init process as PID 1 which forwards signals and reaps zombie processes",
+		},
+		cli.StringFlag{
+			Name:  "init-path",
+			Usage: "`path` to the init binary to use with --init",
+		},
 		cli.StringFlag{
 			Name:  "runtime",
 			Usage: "`path` to an alternate runtime",
@@ -36,6 +49,10 @@ var (
 			Name:  "volume, v",
 			Usage: "bind mount a host location into the container while running the command",
 		},
+		cli.StringFlag{
+			Name:  "workingdir",
+			Usage: "run the command in `directory` instead of the container's configured working directory",
+		},
 	}
 	runDescription = "Runs a specified command using the container's root filesystem as a root\n   filesystem, using configuration settings inherited from the container's\n   image or as specified using previous calls to the config command"
 	runCommand     = cli.Command{
@@ -77,6 +94,15 @@ func runCmd(c *cli.Context) error {
 		Hostname: c.String("hostname"),
 		Runtime:  c.String("runtime"),
 		Args:     c.StringSlice("runtime-flag"),
+		Init:     c.Bool("init"),
+		InitPath: c.String("init-path"),
+	}
+	if c.IsSet("workingdir") {
+		workingDir := c.String("workingdir")
+		if !filepath.IsAbs(workingDir) {
+			return errors.Errorf("working directory %q is not an absolute path", workingDir)
+		}
+		options.WorkingDir = workingDir
 	}
 
 	if c.IsSet("tty") {
@@ -104,6 +130,15 @@ func runCmd(c *cli.Context) error {
 			options.Mounts = append(options.Mounts, mount)
 		}
 	}
+	if c.IsSet("gpus") {
+		gpus, err := buildah.GPUDevices(c.String("gpus"))
+		if err != nil {
+			return err
+		}
+		options.Env = append(options.Env, gpus.Env...)
+		options.Mounts = append(options.Mounts, gpus.Mounts...)
+		options.Devices = append(options.Devices, gpus.Devices...)
+	}
 	runerr := builder.Run(args, options)
 	if runerr != nil {
 		logrus.Debugf("error running %v in container %q: %v", args, builder.Container, runerr)
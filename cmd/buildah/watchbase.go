@@ -0,0 +1,155 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/projectatomic/buildah"
+	"github.com/projectatomic/buildah/util"
+	"github.com/sirupsen/logrus"
+	"github.com/urfave/cli"
+)
+
+var (
+	watchBaseFlags = []cli.Flag{
+		cli.StringFlag{
+			Name:  "authfile",
+			Usage: "path of the authentication file. Default is ${XDG_RUNTIME_DIR}/containers/auth.json",
+		},
+		cli.StringFlag{
+			Name:  "cert-dir",
+			Value: "",
+			Usage: "use certificates at the specified path to access the registry",
+		},
+		cli.StringFlag{
+			Name:  "creds",
+			Value: "",
+			Usage: "use `[username[:password]]` for accessing the registry",
+		},
+		cli.StringFlag{
+			Name:  "exec",
+			Usage: "run `command` using /bin/sh when an image changes",
+		},
+		cli.StringFlag{
+			Name:  "host-policy",
+			Usage: "`path` to a JSON file listing registries which may or may not be contacted",
+		},
+		cli.DurationFlag{
+			Name:  "interval",
+			Value: time.Hour,
+			Usage: "how long to wait between checks",
+		},
+		cli.StringSliceFlag{
+			Name:  "notify",
+			Usage: "send an event to `target`, an http or https URL or the path of a file or named pipe, when an image changes",
+		},
+		cli.BoolFlag{
+			Name:  "once",
+			Usage: "check the images once, and then exit",
+		},
+		cli.StringFlag{
+			Name:  "signature-policy",
+			Usage: "`pathname` of signature policy file (not usually used)",
+		},
+		cli.StringFlag{
+			Name:  "state",
+			Usage: "`path` of a file in which to record the images' digests between runs",
+		},
+		cli.BoolTFlag{
+			Name:  "tls-verify",
+			Usage: "require HTTPS and verify certificates when accessing the registry",
+		},
+	}
+	watchBaseDescription = "Periodically checks images, usually in registries, for changes, and\n   runs a command or sends an event when one of them is updated, so that images\n   which are built from them can be rebuilt."
+	watchBaseCommand     = cli.Command{
+		Name:        "watch-base",
+		Usage:       "Watch base images for changes",
+		Description: watchBaseDescription,
+		Flags:       watchBaseFlags,
+		Action:      watchBaseCmd,
+		ArgsUsage:   "IMAGE [...]",
+	}
+)
+
+// baseImageEvent is the payload which is sent to notification targets when a
+// base image changes.
+type baseImageEvent struct {
+	Type string `json:"type"`
+	buildah.BaseImageUpdate
+}
+
+func watchBaseCmd(c *cli.Context) error {
+	images := c.Args()
+	if len(images) == 0 {
+		return errors.Errorf("at least one image must be specified")
+	}
+	if err := validateFlags(c, watchBaseFlags); err != nil {
+		return err
+	}
+	systemContext, err := systemContextFromOptions(c)
+	if err != nil {
+		return errors.Wrapf(err, "error building system context")
+	}
+	hostPolicy, err := hostPolicyFromOptions(c)
+	if err != nil {
+		return err
+	}
+	targets := c.StringSlice("notify")
+	for _, target := range targets {
+		host, err := util.ParseNotificationTarget(target)
+		if err != nil {
+			return err
+		}
+		if err = hostPolicy.Check("notify", target, host); err != nil {
+			return err
+		}
+	}
+	command := c.String("exec")
+	if command == "" && len(targets) == 0 {
+		logrus.Debugf("no --exec or --notify specified, only reporting changes")
+	}
+
+	options := buildah.WatchOptions{
+		Images:        images,
+		Interval:      c.Duration("interval"),
+		Once:          c.Bool("once"),
+		StatePath:     c.String("state"),
+		SystemContext: systemContext,
+		HostPolicy:    hostPolicy,
+		OnUpdate: func(update buildah.BaseImageUpdate) error {
+			fmt.Printf("%s changed from %s to %s\n", update.Image, update.PreviousDigest, update.Digest)
+			if len(targets) > 0 {
+				data, err := json.Marshal(baseImageEvent{Type: "base-updated", BaseImageUpdate: update})
+				if err != nil {
+					return errors.Wrapf(err, "error encoding event")
+				}
+				for _, target := range targets {
+					if err = util.SendNotification(target, data); err != nil {
+						logrus.Warnf("error sending event to %q: %v", target, err)
+					}
+				}
+			}
+			if command == "" {
+				return nil
+			}
+			cmd := exec.Command("/bin/sh", "-c", command)
+			cmd.Env = append(os.Environ(),
+				"BUILDAH_BASE_IMAGE="+update.Image,
+				"BUILDAH_BASE_DIGEST="+update.Digest,
+				"BUILDAH_BASE_PREVIOUS_DIGEST="+update.PreviousDigest,
+			)
+			cmd.Stdin = os.Stdin
+			cmd.Stdout = os.Stdout
+			cmd.Stderr = os.Stderr
+			if err := cmd.Run(); err != nil {
+				return errors.Wrapf(err, "error running %q", command)
+			}
+			return nil
+		},
+	}
+	return buildah.WatchBaseImages(options)
+}
@@ -49,6 +49,11 @@ type CommitOptions struct {
 	// the transport to which we're writing the image gives us a way to add
 	// them.
 	AdditionalTags []string
+	// AutoTagFromLabel is the name of a label, for example
+	// "org.opencontainers.image.version", whose value, if it is a semantic
+	// version, is used to derive additional tags for the image based on its
+	// major version, its major and minor versions, and "latest".
+	AutoTagFromLabel string
 	// ReportWriter is an io.Writer which will be used to log the writing
 	// of the new image.
 	ReportWriter io.Writer
@@ -58,6 +63,12 @@ type CommitOptions struct {
 	// github.com/containers/image/types SystemContext to hold credentials
 	// and other authentication/authorization information.
 	SystemContext *types.SystemContext
+	// BaseImage, if set, is the name of an image which is recorded as the
+	// new image's base, in place of the one which the container was
+	// created from, for example a mirrored copy of it.  Its layers must
+	// be the same as those of the image which the container was created
+	// from.
+	BaseImage string
 }
 
 // PushOptions can be used to alter how an image is copied somewhere.
@@ -83,6 +94,8 @@ type PushOptions struct {
 	// ManifestType is the format to use when saving the imge using the 'dir' transport
 	// possible options are oci, v2s1, and v2s2
 	ManifestType string
+	// HostPolicy restricts which registries the image can be pushed to.
+	HostPolicy *HostPolicy
 }
 
 // shallowCopy copies the most recent layer, the configuration, and the manifest from one image to another.
@@ -166,7 +179,19 @@ func (b *Builder) shallowCopy(dest types.ImageReference, src types.ImageReferenc
 		}
 		bigdata[itemName] = data
 	}
-	// Delete the image so that we can recreate it.
+	// Delete the image so that we can recreate it.  Hold the storage lock
+	// until it's been recreated, so that other builds which are committing
+	// an image with the same ID, or adding names to it, don't interfere.
+	unlock, err := util.LockStore(b.store)
+	if err != nil {
+		return err
+	}
+	locked := true
+	defer func() {
+		if locked {
+			unlock()
+		}
+	}()
 	_, err = b.store.DeleteImage(destImg.ID, true)
 	if err != nil {
 		return errors.Wrapf(err, "error deleting image %q for rewriting", destImg.ID)
@@ -222,6 +247,9 @@ func (b *Builder) shallowCopy(dest types.ImageReference, src types.ImageReferenc
 		}
 		logrus.Debugf("saved data item %q to %q", itemName, image.ID)
 	}
+	// AddImageNames takes the lock itself.
+	unlock()
+	locked = false
 	// Add the target name(s) to the new image.
 	if len(names) > 0 {
 		err = util.AddImageNames(b.store, image, names)
@@ -237,6 +265,9 @@ func (b *Builder) shallowCopy(dest types.ImageReference, src types.ImageReferenc
 // configuration, to a new image in the specified location, and if we know how,
 // add any additional tags that were specified.
 func (b *Builder) Commit(dest types.ImageReference, options CommitOptions) error {
+	if err := b.HostPolicy.CheckReference("push", dest); err != nil {
+		return err
+	}
 	policy, err := signature.DefaultPolicy(getSystemContext(options.SignaturePolicyPath))
 	if err != nil {
 		return errors.Wrapf(err, "error obtaining default signature policy")
@@ -250,10 +281,16 @@ func (b *Builder) Commit(dest types.ImageReference, options CommitOptions) error
 			logrus.Debugf("error destroying signature polcy context: %v", err2)
 		}
 	}()
+	builder := b
+	if options.BaseImage != "" {
+		if builder, err = b.withBaseImage(options.BaseImage, options.SystemContext); err != nil {
+			return err
+		}
+	}
 	// Check if we're keeping everything in local storage.  If so, we can take certain shortcuts.
 	_, destIsStorage := dest.Transport().(is.StoreTransport)
 	exporting := !destIsStorage
-	src, err := b.makeContainerImageRef(options.PreferredManifestType, exporting, options.Compression, options.HistoryTimestamp)
+	src, err := builder.makeContainerImageRef(options.PreferredManifestType, exporting, options.Compression, options.HistoryTimestamp)
 	if err != nil {
 		return errors.Wrapf(err, "error computing layer digests and building metadata")
 	}
@@ -270,16 +307,24 @@ func (b *Builder) Commit(dest types.ImageReference, options CommitOptions) error
 			return errors.Wrapf(err, "error copying layer and metadata")
 		}
 	}
-	if len(options.AdditionalTags) > 0 {
+	additionalTags := options.AdditionalTags
+	if options.AutoTagFromLabel != "" {
+		versionTags, err := b.versionTags(dest, options.AutoTagFromLabel)
+		if err != nil {
+			return err
+		}
+		additionalTags = append(append([]string{}, additionalTags...), versionTags...)
+	}
+	if len(additionalTags) > 0 {
 		switch dest.Transport().Name() {
 		case is.Transport.Name():
 			img, err := is.Transport.GetStoreImage(b.store, dest)
 			if err != nil {
 				return errors.Wrapf(err, "error locating just-written image %q", transports.ImageName(dest))
 			}
-			err = util.AddImageNames(b.store, img, options.AdditionalTags)
+			err = util.AddImageNames(b.store, img, additionalTags)
 			if err != nil {
-				return errors.Wrapf(err, "error setting image names to %v", append(img.Names, options.AdditionalTags...))
+				return errors.Wrapf(err, "error setting image names to %v", append(img.Names, additionalTags...))
 			}
 			logrus.Debugf("assigned names %v to image %q", img.Names, img.ID)
 		default:
@@ -289,8 +334,30 @@ func (b *Builder) Commit(dest types.ImageReference, options CommitOptions) error
 	return nil
 }
 
+// versionTags computes the names which should be added to an image which is
+// being written to dest, based on the version number in the specified label.
+func (b *Builder) versionTags(dest types.ImageReference, label string) ([]string, error) {
+	version, ok := b.Labels()[label]
+	if !ok || version == "" {
+		logrus.Debugf("no value for label %q, not adding version tags", label)
+		return nil, nil
+	}
+	named := dest.DockerReference()
+	if named == nil {
+		return nil, errors.Errorf("unable to add tags based on label %q to image %q, which has no name", label, transports.ImageName(dest))
+	}
+	tags, err := util.VersionTags(named.Name(), version)
+	if err != nil {
+		return nil, errors.Wrapf(err, "error computing tags from label %q", label)
+	}
+	return tags, nil
+}
+
 // Push copies the contents of the image to a new location.
 func Push(image string, dest types.ImageReference, options PushOptions) error {
+	if err := options.HostPolicy.CheckReference("push", dest); err != nil {
+		return err
+	}
 	systemContext := getSystemContext(options.SignaturePolicyPath)
 	policy, err := signature.DefaultPolicy(systemContext)
 	if err != nil {
@@ -0,0 +1,246 @@
+// Package dockerfile parses Dockerfiles using the same parser which buildah
+// uses when building images, and describes their contents in terms of
+// instructions, build stages, and build arguments, so that other tools can
+// lint, rewrite, or analyze them.
+package dockerfile
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+	"unicode"
+
+	"github.com/docker/docker/builder/dockerfile/command"
+	"github.com/docker/docker/builder/dockerfile/parser"
+	"github.com/pkg/errors"
+)
+
+// Instruction is a single instruction in a Dockerfile.
+type Instruction struct {
+	// Command is the instruction's keyword, in lower case, e.g. "run".
+	Command string
+	// Original is the text of the instruction, with any line
+	// continuations removed.
+	Original string
+	// Flags is the list of flags which were given to the instruction,
+	// e.g. "--from=builder", in the order in which they were given.
+	Flags []string
+	// Args is the list of the instruction's arguments.  For ENV and LABEL
+	// instructions, names and values alternate.
+	Args []string
+	// JSON is true if the arguments were given as a JSON array.
+	JSON bool
+	// OnBuild is the instruction which an ONBUILD instruction registers.
+	OnBuild *Instruction
+	// StartLine and EndLine are the first and last lines of the
+	// Dockerfile which the instruction occupies, counting from 1.
+	StartLine int
+	EndLine   int
+}
+
+// Arg is a build argument which is declared by an ARG instruction.
+type Arg struct {
+	// Name is the name of the argument.
+	Name string
+	// Value is the argument's default value.
+	Value string
+	// HasDefault is true if the ARG instruction supplied a default value,
+	// even if it was empty.
+	HasDefault bool
+	// Line is the line on which the argument is declared.
+	Line int
+}
+
+// Stage is a part of a Dockerfile which starts with a FROM instruction.
+type Stage struct {
+	// Index is the position of the stage in the Dockerfile, counting from
+	// 0.
+	Index int
+	// Name is the name which the FROM instruction gave the stage using
+	// "AS name", if it gave it one.
+	Name string
+	// BaseImage is the image named in the FROM instruction, before any
+	// build arguments are substituted into it.
+	BaseImage string
+	// Args is the list of build arguments which are declared in the
+	// stage.
+	Args []Arg
+	// Instructions is the list of instructions in the stage, starting
+	// with its FROM instruction.
+	Instructions []*Instruction
+}
+
+// Dockerfile is a parsed Dockerfile.
+type Dockerfile struct {
+	// Instructions is the list of every instruction in the Dockerfile.
+	Instructions []*Instruction
+	// GlobalArgs is the list of build arguments which are declared before
+	// the first FROM instruction, which can be used in FROM instructions.
+	GlobalArgs []Arg
+	// Stages is the list of stages in the Dockerfile.
+	Stages []Stage
+	// EscapeToken is the character which is used to escape newlines and
+	// other characters, which can be set using an "escape" directive.
+	EscapeToken rune
+	// Warnings is a list of problems which the parser noticed, but which
+	// did not prevent it from parsing the Dockerfile.
+	Warnings []string
+}
+
+// ParseError is returned when a Dockerfile can't be parsed.
+type ParseError struct {
+	// Line is the line which couldn't be parsed, or 0 if it isn't known.
+	Line int
+	// Err is the error which the parser returned.
+	Err error
+}
+
+func (e *ParseError) Error() string {
+	if e.Line > 0 {
+		return fmt.Sprintf("line %d: %v", e.Line, e.Err)
+	}
+	return e.Err.Error()
+}
+
+// Cause returns the error which the parser returned.
+func (e *ParseError) Cause() error {
+	return e.Err
+}
+
+// ParseFile reads and parses a Dockerfile.
+func ParseFile(path string) (*Dockerfile, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, errors.Wrapf(err, "error opening %q", path)
+	}
+	defer f.Close()
+	d, err := Parse(f)
+	if err != nil {
+		return nil, errors.Wrapf(err, "error parsing %q", path)
+	}
+	return d, nil
+}
+
+// Parse parses a Dockerfile.  If the Dockerfile can't be parsed, the error
+// is a *ParseError.
+func Parse(r io.Reader) (*Dockerfile, error) {
+	contents, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, errors.Wrapf(err, "error reading dockerfile")
+	}
+	result, err := parser.Parse(bytes.NewReader(contents))
+	if err != nil {
+		return nil, &ParseError{Line: errorLine(contents), Err: err}
+	}
+	lines := splitLines(contents)
+	d := &Dockerfile{
+		EscapeToken: result.EscapeToken,
+		Warnings:    result.Warnings,
+	}
+	for _, node := range result.AST.Children {
+		instruction := newInstruction(node)
+		instruction.EndLine = endLine(lines, node.StartLine, result.EscapeToken)
+		d.Instructions = append(d.Instructions, instruction)
+		switch instruction.Command {
+		case command.From:
+			stage := Stage{Index: len(d.Stages)}
+			if len(instruction.Args) > 0 {
+				stage.BaseImage = instruction.Args[0]
+			}
+			if len(instruction.Args) > 2 && strings.EqualFold(instruction.Args[1], "as") {
+				stage.Name = instruction.Args[2]
+			}
+			d.Stages = append(d.Stages, stage)
+		case command.Arg:
+			for _, arg := range instruction.Args {
+				a := Arg{Name: arg, Line: instruction.StartLine}
+				if i := strings.Index(arg, "="); i >= 0 {
+					a.Name, a.Value, a.HasDefault = arg[:i], arg[i+1:], true
+				}
+				if len(d.Stages) == 0 {
+					d.GlobalArgs = append(d.GlobalArgs, a)
+				} else {
+					stage := &d.Stages[len(d.Stages)-1]
+					stage.Args = append(stage.Args, a)
+				}
+			}
+		}
+		if len(d.Stages) > 0 {
+			stage := &d.Stages[len(d.Stages)-1]
+			stage.Instructions = append(stage.Instructions, instruction)
+		}
+	}
+	return d, nil
+}
+
+// newInstruction converts a node from the parser's syntax tree.
+func newInstruction(node *parser.Node) *Instruction {
+	instruction := &Instruction{
+		Command:   node.Value,
+		Original:  node.Original,
+		Flags:     append([]string{}, node.Flags...),
+		JSON:      node.Attributes["json"],
+		StartLine: node.StartLine,
+		EndLine:   node.StartLine,
+	}
+	for next := node.Next; next != nil; next = next.Next {
+		if len(next.Children) > 0 {
+			instruction.OnBuild = newInstruction(next.Children[0])
+			continue
+		}
+		instruction.Args = append(instruction.Args, next.Value)
+	}
+	return instruction
+}
+
+// splitLines splits a Dockerfile into lines.
+func splitLines(contents []byte) []string {
+	lines := []string{}
+	scanner := bufio.NewScanner(bytes.NewReader(contents))
+	for scanner.Scan() {
+		lines = append(lines, scanner.Text())
+	}
+	return lines
+}
+
+// endLine finds the last line of an instruction which starts on the specified
+// line, by following line continuations, and skipping the empty lines and
+// comments which the parser allows to appear between them.
+func endLine(lines []string, start int, escapeToken rune) int {
+	end := start
+	for end <= len(lines) {
+		line := strings.TrimRightFunc(lines[end-1], unicode.IsSpace)
+		if end > start {
+			trimmed := strings.TrimSpace(line)
+			if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+				end++
+				continue
+			}
+		}
+		if !strings.HasSuffix(line, string(escapeToken)) || end == len(lines) {
+			break
+		}
+		end++
+	}
+	if end > len(lines) {
+		end = len(lines)
+	}
+	return end
+}
+
+// errorLine finds the line of a Dockerfile which the parser rejects, by
+// parsing successively longer portions of it, since the parser doesn't report
+// it.  It returns 0 if it can't find the line.
+func errorLine(contents []byte) int {
+	lines := bytes.SplitAfter(contents, []byte("\n"))
+	for i := range lines {
+		if _, err := parser.Parse(bytes.NewReader(bytes.Join(lines[:i+1], nil))); err != nil {
+			return i + 1
+		}
+	}
+	return 0
+}
@@ -0,0 +1,66 @@
+package dockerfile
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	d, err := Parse(strings.NewReader(`ARG BASE=alpine
+ARG TAG
+FROM ${BASE}:${TAG} AS builder
+# comment
+RUN --network=none echo \
+  hello \
+
+  world
+ONBUILD RUN make
+
+FROM scratch
+ARG VERSION=1.0
+COPY --from=builder ["/a", "/b"]
+`))
+	if err != nil {
+		t.Fatalf("error parsing: %v", err)
+	}
+	expectedArgs := []Arg{
+		{Name: "BASE", Value: "alpine", HasDefault: true, Line: 1},
+		{Name: "TAG", Line: 2},
+	}
+	if !reflect.DeepEqual(d.GlobalArgs, expectedArgs) {
+		t.Errorf("expected global args %#v, got %#v", expectedArgs, d.GlobalArgs)
+	}
+	if len(d.Stages) != 2 {
+		t.Fatalf("expected 2 stages, got %d", len(d.Stages))
+	}
+	if d.Stages[0].Name != "builder" || d.Stages[0].BaseImage != "${BASE}:${TAG}" || len(d.Stages[0].Instructions) != 3 {
+		t.Errorf("unexpected first stage %#v", d.Stages[0])
+	}
+	if d.Stages[1].Name != "" || d.Stages[1].BaseImage != "scratch" || len(d.Stages[1].Args) != 1 || d.Stages[1].Args[0].Value != "1.0" {
+		t.Errorf("unexpected second stage %#v", d.Stages[1])
+	}
+	run := d.Stages[0].Instructions[1]
+	if run.Command != "run" || run.StartLine != 5 || run.EndLine != 8 || !reflect.DeepEqual(run.Flags, []string{"--network=none"}) {
+		t.Errorf("unexpected RUN instruction %#v", run)
+	}
+	onbuild := d.Stages[0].Instructions[2]
+	if onbuild.OnBuild == nil || onbuild.OnBuild.Command != "run" || !reflect.DeepEqual(onbuild.OnBuild.Args, []string{"make"}) {
+		t.Errorf("unexpected ONBUILD instruction %#v", onbuild)
+	}
+	copy := d.Stages[1].Instructions[2]
+	if !copy.JSON || !reflect.DeepEqual(copy.Args, []string{"/a", "/b"}) || copy.EndLine != 13 {
+		t.Errorf("unexpected COPY instruction %#v", copy)
+	}
+}
+
+func TestParseError(t *testing.T) {
+	_, err := Parse(strings.NewReader("FROM alpine\n\nRUN echo \\\n  hello\nENV FOO\n"))
+	perr, ok := err.(*ParseError)
+	if !ok {
+		t.Fatalf("expected a *ParseError, got %v", err)
+	}
+	if perr.Line != 5 {
+		t.Errorf("expected parse error on line 5, got %d", perr.Line)
+	}
+}
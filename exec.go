@@ -0,0 +1,114 @@
+package buildah
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"golang.org/x/crypto/ssh/terminal"
+)
+
+// runtimeContainerName returns the name which we give the runtime for the
+// container in which we run commands.
+func (b *Builder) runtimeContainerName() string {
+	return Package + "-" + b.ContainerID
+}
+
+// runtimeState is the subset of the output of the runtime's "state" command
+// which we care about.
+type runtimeState struct {
+	Status string `json:"status"`
+	Pid    int    `json:"pid"`
+}
+
+// Running checks if a command which was started using Run() is currently
+// running in the container.
+func (b *Builder) Running(runtime string, runtimeArgs []string) (bool, error) {
+	if runtime == "" {
+		runtime = DefaultRuntime
+	}
+	args := append(append([]string{}, runtimeArgs...), "state", b.runtimeContainerName())
+	output, err := exec.Command(runtime, args...).Output()
+	if err != nil {
+		// The runtime doesn't know about the container, so nothing is
+		// running in it.
+		logrus.Debugf("error checking state of %q: %v", b.runtimeContainerName(), err)
+		return false, nil
+	}
+	state := runtimeState{}
+	if err = json.Unmarshal(output, &state); err != nil {
+		return false, errors.Wrapf(err, "error parsing state of %q", b.runtimeContainerName())
+	}
+	return state.Status == "running", nil
+}
+
+// Exec starts an additional process alongside a command which is currently
+// being run in the container by Run(), sharing its namespaces, so that a
+// long-running build step can be inspected without interrupting it.  The
+// Runtime, Args, Env, User, WorkingDir, and Terminal fields of the options are
+// used, and should match those which were used for the running command.
+func (b *Builder) Exec(command []string, options RunOptions) error {
+	if len(command) == 0 {
+		return errors.Errorf("no command specified")
+	}
+	running, err := b.Running(options.Runtime, options.Args)
+	if err != nil {
+		return err
+	}
+	if !running {
+		return errors.Errorf("no command is currently running in container %q", b.Container)
+	}
+	runtime := options.Runtime
+	if runtime == "" {
+		runtime = DefaultRuntime
+	}
+	args := append(append([]string{}, options.Args...), "exec")
+	switch options.Terminal {
+	case DefaultTerminal:
+		if terminal.IsTerminal(int(os.Stdout.Fd())) {
+			args = append(args, "--tty")
+		}
+	case WithTerminal:
+		args = append(args, "--tty")
+	}
+	if options.WorkingDir != "" {
+		args = append(args, "--cwd", options.WorkingDir)
+	}
+	for _, env := range options.Env {
+		args = append(args, "--env", env)
+	}
+	if options.User != "" {
+		mountPoint, err := b.Mount(b.MountLabel)
+		if err != nil {
+			return err
+		}
+		user, err := getUser(mountPoint, options.User)
+		if err2 := b.Unmount(); err2 != nil {
+			logrus.Errorf("error unmounting container: %v", err2)
+		}
+		if err != nil {
+			return err
+		}
+		args = append(args, "--user", fmt.Sprintf("%d:%d", user.UID, user.GID))
+	}
+	args = append(args, b.runtimeContainerName())
+	args = append(args, command...)
+	cmd := exec.Command(runtime, args...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	if options.Stdout != nil {
+		cmd.Stdout = options.Stdout
+	}
+	cmd.Stderr = os.Stderr
+	if options.Stderr != nil {
+		cmd.Stderr = options.Stderr
+	}
+	err = cmd.Run()
+	if err != nil {
+		logrus.Debugf("error running %v in %q: %v", command, b.runtimeContainerName(), err)
+	}
+	return err
+}
@@ -0,0 +1,63 @@
+package buildah
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+
+	cp "github.com/containers/image/copy"
+	"github.com/containers/image/docker/daemon"
+	"github.com/containers/image/docker/reference"
+	"github.com/containers/image/signature"
+	"github.com/containers/image/transports"
+	"github.com/containers/image/types"
+	"github.com/pkg/errors"
+)
+
+// DefaultContainerdContentStore is the usual location of containerd's content
+// store.
+const DefaultContainerdContentStore = "/var/lib/containerd/io.containerd.content.v1.content"
+
+// ContainerdContentStore is a BlobFetcher which reads the layers of images
+// which a co-installed containerd has already pulled from its content store,
+// without modifying it.  Image manifests are still read from the images'
+// registries, since the content store doesn't index blobs by image name.
+type ContainerdContentStore struct {
+	blobs BlobCache
+}
+
+// NewContainerdContentStore returns a ContainerdContentStore which reads from
+// the content store at the specified location, or at
+// DefaultContainerdContentStore if none is specified.
+func NewContainerdContentStore(root string) (*ContainerdContentStore, error) {
+	if root == "" {
+		root = DefaultContainerdContentStore
+	}
+	blobs := filepath.Join(root, "blobs")
+	st, err := os.Stat(blobs)
+	if err != nil {
+		return nil, errors.Wrapf(err, "error reading containerd content store at %q", root)
+	}
+	if !st.IsDir() {
+		return nil, errors.Errorf("containerd content store %q is not a directory", blobs)
+	}
+	return &ContainerdContentStore{blobs: BlobCache{dir: blobs}}, nil
+}
+
+// FetchBlob reads a blob from the content store.
+func (c *ContainerdContentStore) FetchBlob(ref types.ImageReference, info types.BlobInfo) (io.ReadCloser, int64, error) {
+	return c.blobs.FetchBlob(ref, info)
+}
+
+// copyFromDockerDaemon copies an image which would otherwise be pulled from a
+// registry from the local Docker daemon, if it has a copy of it.
+func copyFromDockerDaemon(policyContext *signature.PolicyContext, destRef, srcRef types.ImageReference, options BuilderOptions) error {
+	if srcRef.Transport().Name() != "docker" || srcRef.DockerReference() == nil {
+		return errors.Errorf("%q is not an image in a registry", transports.ImageName(srcRef))
+	}
+	daemonRef, err := daemon.NewReference("", reference.TagNameOnly(srcRef.DockerReference()))
+	if err != nil {
+		return errors.Wrapf(err, "error building reference to %q in the Docker daemon", transports.ImageName(srcRef))
+	}
+	return cp.Image(policyContext, destRef, daemonRef, getCopyOptions(options.ReportWriter, options.SystemContext, nil, ""))
+}
@@ -0,0 +1,54 @@
+package buildah
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/containers/image/types"
+	digest "github.com/opencontainers/go-digest"
+	"github.com/pkg/errors"
+)
+
+func TestContainerdContentStore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "buildah-containerd")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	if _, err = NewContainerdContentStore(dir); err == nil {
+		t.Fatalf("expected an error opening a directory which isn't a content store")
+	}
+
+	blob := []byte("layer contents")
+	d := digest.FromBytes(blob)
+	if err = os.MkdirAll(filepath.Join(dir, "blobs", "sha256"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err = ioutil.WriteFile(filepath.Join(dir, "blobs", "sha256", d.Hex()), blob, 0644); err != nil {
+		t.Fatal(err)
+	}
+	store, err := NewContainerdContentStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rc, size, err := store.FetchBlob(nil, types.BlobInfo{Digest: d, Size: -1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := ioutil.ReadAll(rc)
+	rc.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != string(blob) || size != int64(len(blob)) {
+		t.Fatalf("expected %q (%d bytes), got %q (%d bytes)", blob, len(blob), data, size)
+	}
+	if _, _, err = store.FetchBlob(nil, types.BlobInfo{Digest: digest.FromString("missing"), Size: -1}); errors.Cause(err) != ErrBlobNotFound {
+		t.Fatalf("expected ErrBlobNotFound for a missing blob, got %v", err)
+	}
+	if _, ok := interface{}(store).(BlobSaver); ok {
+		t.Fatalf("expected the content store to be read-only")
+	}
+}
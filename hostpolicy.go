@@ -0,0 +1,112 @@
+package buildah
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"strings"
+
+	"github.com/containers/image/docker/reference"
+	"github.com/containers/image/types"
+	"github.com/pkg/errors"
+)
+
+// HostPolicy restricts which registries and hosts may be contacted when
+// pulling images, pushing images, and downloading content for ADD.  Each entry
+// is either a host name, a host name and port, or a pattern like
+// "*.example.com" which matches any host in that domain.
+type HostPolicy struct {
+	// Allowed is a list of hosts which may be contacted.  If it is empty,
+	// any host which is not denied may be contacted.
+	Allowed []string `json:"allowed,omitempty"`
+	// Denied is a list of hosts which may not be contacted, even if they
+	// are also allowed.
+	Denied []string `json:"denied,omitempty"`
+}
+
+// HostPolicyError is returned when an operation would contact a host which
+// the HostPolicy does not allow.
+type HostPolicyError struct {
+	// Operation is what we were trying to do, e.g. "pull", "push", or
+	// "add".
+	Operation string `json:"operation"`
+	// Host is the registry or host which we would have contacted.
+	Host string `json:"host"`
+	// Target is the image name or URL which we were trying to use.
+	Target string `json:"target"`
+	// Rule is the entry in the policy's denied list which matched the
+	// host, or empty if the host wasn't in the allowed list.
+	Rule string `json:"rule,omitempty"`
+}
+
+func (e *HostPolicyError) Error() string {
+	if e.Rule != "" {
+		return fmt.Sprintf("host policy violation: %s of %q would contact %q, which is denied by %q", e.Operation, e.Target, e.Host, e.Rule)
+	}
+	return fmt.Sprintf("host policy violation: %s of %q would contact %q, which is not allowed", e.Operation, e.Target, e.Host)
+}
+
+// LoadHostPolicy reads a HostPolicy from a JSON file.
+func LoadHostPolicy(path string) (*HostPolicy, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrapf(err, "error reading host policy from %q", path)
+	}
+	policy := HostPolicy{}
+	if err = json.Unmarshal(data, &policy); err != nil {
+		return nil, errors.Wrapf(err, "error parsing host policy in %q", path)
+	}
+	return &policy, nil
+}
+
+// hostMatches checks if a host, which may include a port, matches a policy
+// entry.
+func hostMatches(pattern, host string) bool {
+	candidates := []string{host}
+	if hostname, _, err := net.SplitHostPort(host); err == nil {
+		candidates = append(candidates, hostname)
+	}
+	for _, candidate := range candidates {
+		if strings.EqualFold(pattern, candidate) {
+			return true
+		}
+		if strings.HasPrefix(pattern, "*.") && strings.HasSuffix(strings.ToLower(candidate), strings.ToLower(pattern[1:])) {
+			return true
+		}
+	}
+	return false
+}
+
+// Check returns a *HostPolicyError if the policy doesn't allow contacting the
+// specified host as part of the specified operation on target.  A nil policy
+// allows everything.
+func (p *HostPolicy) Check(operation, target, host string) error {
+	if p == nil || host == "" {
+		return nil
+	}
+	for _, denied := range p.Denied {
+		if hostMatches(denied, host) {
+			return &HostPolicyError{Operation: operation, Host: host, Target: target, Rule: denied}
+		}
+	}
+	if len(p.Allowed) == 0 {
+		return nil
+	}
+	for _, allowed := range p.Allowed {
+		if hostMatches(allowed, host) {
+			return nil
+		}
+	}
+	return &HostPolicyError{Operation: operation, Host: host, Target: target}
+}
+
+// CheckReference checks an image reference against the policy.  References
+// which don't refer to images in registries are always allowed.
+func (p *HostPolicy) CheckReference(operation string, ref types.ImageReference) error {
+	if p == nil || ref == nil || ref.Transport().Name() != "docker" || ref.DockerReference() == nil {
+		return nil
+	}
+	named := ref.DockerReference()
+	return p.Check(operation, named.String(), reference.Domain(named))
+}
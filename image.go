@@ -459,7 +459,29 @@ func (b *Builder) makeImageRef(manifestType string, exporting, addHistory bool,
 	if err != nil {
 		return nil, errors.Wrapf(err, "error encoding OCI-format image configuration")
 	}
-	dconfig, err := json.Marshal(&b.Docker)
+	dimage := b.Docker
+	annotations := b.Annotations()
+	if addHistory {
+		// Record which image this one is being built from, replacing
+		// any record of what that image was built from.
+		dimage.Parent = ""
+		if b.FromImageID != "" {
+			if d, err2 := digest.Parse(b.FromImageID); err2 == nil {
+				dimage.Parent = docker.ID(d)
+			} else {
+				dimage.Parent = docker.ID(digest.NewDigestFromHex(digest.Canonical.String(), b.FromImageID))
+			}
+		}
+		delete(annotations, BaseImageNameAnnotation)
+		delete(annotations, BaseImageDigestAnnotation)
+		if b.FromImage != "" {
+			annotations[BaseImageNameAnnotation] = b.FromImage
+		}
+		if b.FromImageDigest != "" {
+			annotations[BaseImageDigestAnnotation] = b.FromImageDigest
+		}
+	}
+	dconfig, err := json.Marshal(&dimage)
 	if err != nil {
 		return nil, errors.Wrapf(err, "error encoding docker-format image configuration")
 	}
@@ -478,7 +500,7 @@ func (b *Builder) makeImageRef(manifestType string, exporting, addHistory bool,
 		dconfig:               dconfig,
 		created:               created,
 		createdBy:             b.CreatedBy(),
-		annotations:           b.Annotations(),
+		annotations:           annotations,
 		preferredManifestType: manifestType,
 		exporting:             exporting,
 	}
@@ -1,11 +1,14 @@
 package imagebuildah
 
 import (
+	"bytes"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"net/http"
 	"os"
 	"path/filepath"
+	"runtime"
 	"strings"
 
 	is "github.com/containers/image/storage"
@@ -15,12 +18,15 @@ import (
 	"github.com/containers/storage"
 	"github.com/containers/storage/pkg/archive"
 	"github.com/containers/storage/pkg/stringid"
+	"github.com/docker/docker/builder/dockerfile/command"
 	"github.com/docker/docker/builder/dockerfile/parser"
 	docker "github.com/fsouza/go-dockerclient"
 	"github.com/opencontainers/runtime-spec/specs-go"
 	"github.com/openshift/imagebuilder"
 	"github.com/pkg/errors"
 	"github.com/projectatomic/buildah"
+	"github.com/projectatomic/buildah/dockerfile"
+	"github.com/projectatomic/buildah/util"
 	"github.com/sirupsen/logrus"
 )
 
@@ -61,8 +67,49 @@ type BuildOptions struct {
 	// IgnoreUnrecognizedInstructions tells us to just log instructions we
 	// don't recognize, and try to keep going.
 	IgnoreUnrecognizedInstructions bool
+	// Network controls whether or not RUN instructions have access to the
+	// network.  If it is "none", only RUN instructions which specify
+	// --network=default are given access to the network.  If it is empty
+	// or "default", RUN instructions have access to the network unless
+	// they specify --network=none.
+	Network string
+	// DefaultMountsFilePath is the location of a file which lists
+	// directories on the host, in "host-path:container-path" format,
+	// whose contents should be made available to RUN instructions.
+	DefaultMountsFilePath string
+	// GPUs, if set, is either "all" or a comma-separated list of the names
+	// of GPUs, described by CDI specifications, which should be made
+	// available to RUN instructions.
+	GPUs string
+	// Init causes a minimal init process to be run as PID 1 during RUN
+	// instructions, so that commands which start daemons or leave zombie
+	// processes behind don't cause the build to hang.
+	Init bool
+	// InitPath is the location of the init binary to use for Init.
+	InitPath string
+	// HostPolicy restricts which registries and hosts the build may
+	// contact when pulling base images, writing the output image, and
+	// downloading content for ADD instructions.
+	HostPolicy *buildah.HostPolicy
+	// BlobFetchers is a list of alternate sources, such as caches and
+	// registry mirrors, for the layers of base images which need to be
+	// pulled.
+	BlobFetchers []buildah.BlobFetcher
+	// UseDockerDaemon causes base images which need to be pulled to be
+	// copied from the local Docker daemon instead, if it has them.
+	UseDockerDaemon bool
+	// VCSLabels causes the git revision and source repository of the
+	// context directory to be recorded in the image's labels and
+	// annotations.
+	VCSLabels bool
+	// Strict causes problems which would otherwise only be noted as
+	// warnings to be treated as errors.
+	Strict bool
 	// Quiet tells us whether or not to announce steps as we go through them.
 	Quiet bool
+	// Progress controls how steps are announced.  It can be ProgressPlain,
+	// which is the default, or ProgressCI.
+	Progress string
 	// Runtime is the name of the command to run for RUN instructions.  It
 	// should accept the same arguments and flags that runc does.
 	Runtime string
@@ -70,6 +117,11 @@ type BuildOptions struct {
 	RuntimeArgs []string
 	// TransientMounts is a list of mounts that won't be kept in the image.
 	TransientMounts []Mount
+	// Secrets maps IDs to the values of secrets which will be made
+	// available to RUN instructions as files named /run/secrets/ID, but
+	// which won't be kept in the image.  Their values will be masked in
+	// any output from the build.
+	Secrets map[string]string
 	// Compression specifies the type of compression which is applied to
 	// layer blobs.  The default is to not use compression, but
 	// archive.Gzip is recommended.
@@ -81,6 +133,19 @@ type BuildOptions struct {
 	// Additional tags to add to the image that we write, if we know of a
 	// way to add them.
 	AdditionalTags []string
+	// KeepStages causes the result of each completed stage of the build to
+	// be kept in local storage, named "NAME/stage:N", where NAME is the
+	// repository name of the output image and N is the stage's index, so
+	// that it can be inspected or used as the base image for other builds.
+	KeepStages bool
+	// AutoTagFromLabel is the name of a label whose value, if it is a
+	// semantic version, is used to derive additional tags for the image.
+	AutoTagFromLabel string
+	// Notify is a list of targets which are sent a BuildEvent when the
+	// build starts, succeeds, or fails.  Each can be an http or https URL,
+	// to which the event is POSTed as JSON, or the path of a file or named
+	// pipe, to which the event is written as a line of JSON.
+	Notify []string
 	// Log is a callback that will print a progress message.  If no value
 	// is supplied, the message will be sent to Err (or os.Stderr, if Err
 	// is nil) by default.
@@ -108,6 +173,23 @@ type BuildOptions struct {
 	AuthFilePath string
 }
 
+// Warning describes a problem which was noticed during a build, but which did
+// not cause the build to fail.
+type Warning struct {
+	// Step is the instruction which was being processed when the problem
+	// was noticed, if there was one.
+	Step string `json:"step,omitempty"`
+	// Message describes the problem.
+	Message string `json:"message"`
+}
+
+func (w Warning) String() string {
+	if w.Step != "" {
+		return fmt.Sprintf("%s: %s", w.Step, w.Message)
+	}
+	return w.Message
+}
+
 // Executor is a buildah-based implementation of the imagebuilder.Executor
 // interface.
 type Executor struct {
@@ -118,14 +200,37 @@ type Executor struct {
 	registry                       string
 	transport                      string
 	ignoreUnrecognizedInstructions bool
+	strict                         bool
+	network                        string
+	runFlags                       []string
+	hostPolicy                     *buildah.HostPolicy
+	blobFetchers                   []buildah.BlobFetcher
+	useDockerDaemon                bool
+	vcsLabels                      bool
+	defaultMountsFilePath          string
+	devices                        *buildah.DeviceEdits
+	init                           bool
+	initPath                       string
 	quiet                          bool
+	ci                             *ciReporter
+	currentFile                    string
+	currentLine                    int
 	runtime                        string
 	runtimeArgs                    []string
 	transientMounts                []Mount
+	secrets                        map[string]string
+	secretsDir                     string
 	compression                    archive.Compression
 	output                         string
 	outputFormat                   string
 	additionalTags                 []string
+	keepStages                     bool
+	autoTagFromLabel               string
+	notifyTargets                  []string
+	dockerfileNames                []string
+	imageRef                       types.ImageReference
+	args                           map[string]string
+	warnings                       []Warning
 	log                            func(format string, args ...interface{})
 	out                            io.Writer
 	err                            io.Writer
@@ -380,6 +485,27 @@ func (b *Executor) Run(run imagebuilder.Run, config docker.Config) error {
 		Entrypoint:      config.Entrypoint,
 		Cmd:             config.Cmd,
 		NetworkDisabled: config.NetworkDisabled,
+		Stdout:          b.out,
+		Stderr:          b.err,
+		Init:            b.init,
+		InitPath:        b.initPath,
+	}
+	networkDisabled, err := b.runNetworkDisabled()
+	if err != nil {
+		return err
+	}
+	if networkDisabled {
+		options.NetworkDisabled = true
+	}
+	secretMounts, err := b.secretMounts()
+	if err != nil {
+		return err
+	}
+	options.Mounts = append(options.Mounts, secretMounts...)
+	if b.devices != nil {
+		options.Env = append(append([]string{}, options.Env...), b.devices.Env...)
+		options.Mounts = append(options.Mounts, b.devices.Mounts...)
+		options.Devices = b.devices.Devices
 	}
 
 	args := run.Args
@@ -389,7 +515,8 @@ func (b *Executor) Run(run imagebuilder.Run, config docker.Config) error {
 	if err := b.volumeCacheSave(); err != nil {
 		return err
 	}
-	err := b.builder.Run(args, options)
+	err = b.builder.Run(args, options)
+	b.flushOutput()
 	if err2 := b.volumeCacheRestore(); err2 != nil {
 		if err == nil {
 			return err2
@@ -398,6 +525,38 @@ func (b *Executor) Run(run imagebuilder.Run, config docker.Config) error {
 	return err
 }
 
+// parseNetworkMode checks that a network mode is one which we recognize, and
+// returns whether or not it disables networking.
+func parseNetworkMode(mode string) (bool, error) {
+	switch mode {
+	case "", "default":
+		return false, nil
+	case "none":
+		return true, nil
+	}
+	return false, errors.Errorf("unrecognized network mode %q, expected %q or %q", mode, "default", "none")
+}
+
+// runNetworkDisabled decides whether or not the RUN instruction which we're
+// currently processing should be denied access to the network, based on the
+// build's network mode and any --network flag which was given to the
+// instruction.
+func (b *Executor) runNetworkDisabled() (bool, error) {
+	disabled, err := parseNetworkMode(b.network)
+	if err != nil {
+		return false, err
+	}
+	for _, flag := range b.runFlags {
+		if !strings.HasPrefix(flag, "--network=") {
+			return false, errors.Errorf("unrecognized flag %q for RUN", flag)
+		}
+		if disabled, err = parseNetworkMode(strings.TrimPrefix(flag, "--network=")); err != nil {
+			return false, errors.Wrapf(err, "error parsing flag %q for RUN", flag)
+		}
+	}
+	return disabled, nil
+}
+
 // UnrecognizedInstruction is called when we encounter an instruction that the
 // imagebuilder parser didn't understand.
 func (b *Executor) UnrecognizedInstruction(step *imagebuilder.Step) error {
@@ -409,6 +568,47 @@ func (b *Executor) UnrecognizedInstruction(step *imagebuilder.Step) error {
 	return errors.Errorf("Unrecognized instruction: %#v", step)
 }
 
+// warn records a problem which was noticed while processing a step, or
+// returns it as an error if we're being strict.
+func (b *Executor) warn(step, format string, args ...interface{}) error {
+	w := Warning{
+		Step:    step,
+		Message: fmt.Sprintf(format, args...),
+	}
+	if b.strict {
+		return errors.New(w.String())
+	}
+	logrus.Debugf("warning: %s", w)
+	if b.ci != nil {
+		b.ci.annotate("warning", b.currentFile, b.currentLine, w.String())
+		b.flushOutput()
+	}
+	b.warnings = append(b.warnings, w)
+	return nil
+}
+
+// Warnings returns the list of problems which were noticed during the build,
+// but which didn't cause it to fail.
+func (b *Executor) Warnings() []Warning {
+	return append([]Warning{}, b.warnings...)
+}
+
+// checkStep looks for things in a resolved step which aren't errors, but
+// which are worth mentioning.
+func (b *Executor) checkStep(step *imagebuilder.Step) error {
+	switch step.Command {
+	case command.Maintainer:
+		return b.warn(step.Original, "MAINTAINER is deprecated, use a LABEL instead")
+	case command.Arg:
+		if len(step.Args) == 1 && !strings.Contains(step.Args[0], "=") {
+			if _, ok := b.args[step.Args[0]]; !ok {
+				return b.warn(step.Original, "no value was supplied for build argument %q", step.Args[0])
+			}
+		}
+	}
+	return nil
+}
+
 // NewExecutor creates a new instance of the imagebuilder.Executor interface.
 func NewExecutor(store storage.Store, options BuildOptions) (*Executor, error) {
 	exec := Executor{
@@ -418,14 +618,28 @@ func NewExecutor(store storage.Store, options BuildOptions) (*Executor, error) {
 		registry:                       options.Registry,
 		transport:                      options.Transport,
 		ignoreUnrecognizedInstructions: options.IgnoreUnrecognizedInstructions,
+		strict:                         options.Strict,
+		network:                        options.Network,
+		hostPolicy:                     options.HostPolicy,
+		blobFetchers:                   options.BlobFetchers,
+		useDockerDaemon:                options.UseDockerDaemon,
+		vcsLabels:                      options.VCSLabels,
+		defaultMountsFilePath:          options.DefaultMountsFilePath,
+		init:                           options.Init,
+		initPath:                       options.InitPath,
 		quiet:               options.Quiet,
 		runtime:             options.Runtime,
 		runtimeArgs:         options.RuntimeArgs,
 		transientMounts:     options.TransientMounts,
+		secrets:             options.Secrets,
 		compression:         options.Compression,
 		output:              options.Output,
 		outputFormat:        options.OutputFormat,
 		additionalTags:      options.AdditionalTags,
+		keepStages:          options.KeepStages,
+		autoTagFromLabel:    options.AutoTagFromLabel,
+		notifyTargets:       options.Notify,
+		args:                options.Args,
 		signaturePolicyPath: options.SignaturePolicyPath,
 		systemContext:       makeSystemContext(options.SignaturePolicyPath, options.AuthFilePath, options.SkipTLSVerify),
 		volumeCache:         make(map[string]string),
@@ -435,19 +649,83 @@ func NewExecutor(store storage.Store, options BuildOptions) (*Executor, error) {
 		err:                 options.Err,
 		reportWriter:        options.ReportWriter,
 	}
+	if exec.output != "" {
+		if _, err := alltransports.ParseImageName(exec.output); err != nil {
+			if err = util.ValidateName(exec.output); err != nil {
+				return nil, err
+			}
+		}
+	}
+	for _, tag := range exec.additionalTags {
+		if err := util.ValidateName(tag); err != nil {
+			return nil, err
+		}
+	}
+	switch options.Progress {
+	case "", ProgressPlain, ProgressCI:
+	default:
+		return nil, errors.Errorf("unknown progress output type %q: expected %q or %q", options.Progress, ProgressPlain, ProgressCI)
+	}
+	if exec.autoTagFromLabel != "" && exec.output == "" {
+		return nil, errors.Errorf("adding tags based on label %q requires a name for the output image", exec.autoTagFromLabel)
+	}
+	for _, target := range exec.notifyTargets {
+		if err := exec.checkNotifyTarget(target); err != nil {
+			return nil, err
+		}
+	}
+	if exec.keepStages {
+		if _, err := stageImageName(exec.output, 0, ""); err != nil {
+			return nil, err
+		}
+	}
 	if exec.err == nil {
 		exec.err = os.Stderr
 	}
 	if exec.out == nil {
 		exec.out = os.Stdout
 	}
+	if _, err := parseNetworkMode(exec.network); err != nil {
+		return nil, err
+	}
+	if options.GPUs != "" {
+		devices, err := buildah.GPUDevices(options.GPUs)
+		if err != nil {
+			return nil, err
+		}
+		exec.devices = devices
+	}
+	for id := range exec.secrets {
+		if id == "" || id == "." || id == ".." || strings.Contains(id, "/") {
+			return nil, errors.Errorf("invalid secret ID %q", id)
+		}
+	}
+	if len(exec.secrets) > 0 {
+		exec.out = newMaskingWriter(exec.out, exec.secrets)
+		exec.err = newMaskingWriter(exec.err, exec.secrets)
+	}
+	if options.Progress == ProgressCI {
+		exec.ci = newCIReporter(exec.err)
+	}
 	if exec.log == nil {
 		stepCounter := 0
 		exec.log = func(format string, args ...interface{}) {
 			stepCounter++
 			prefix := fmt.Sprintf("STEP %d: ", stepCounter)
-			suffix := "\n"
-			fmt.Fprintf(exec.err, prefix+format+suffix, args...)
+			if exec.ci != nil {
+				exec.ci.startGroup(fmt.Sprintf(prefix+format, args...))
+			} else {
+				suffix := "\n"
+				fmt.Fprintf(exec.err, prefix+format+suffix, args...)
+			}
+			exec.flushOutput()
+		}
+	} else if len(exec.secrets) > 0 {
+		log := exec.log
+		masker := newMaskingWriter(nil, exec.secrets)
+		exec.log = func(format string, args ...interface{}) {
+			masked, _ := masker.mask([]byte(fmt.Sprintf(format, args...)), true)
+			log("%s", string(masked))
 		}
 	}
 	return &exec, nil
@@ -469,17 +747,29 @@ func (b *Executor) Prepare(ib *imagebuilder.Builder, node *parser.Node, from str
 		b.log("FROM %s", from)
 	}
 	builderOptions := buildah.BuilderOptions{
-		FromImage:           from,
-		PullPolicy:          b.pullPolicy,
-		Registry:            b.registry,
-		Transport:           b.transport,
-		SignaturePolicyPath: b.signaturePolicyPath,
-		ReportWriter:        b.reportWriter,
+		FromImage:             from,
+		PullPolicy:            b.pullPolicy,
+		Registry:              b.registry,
+		Transport:             b.transport,
+		SignaturePolicyPath:   b.signaturePolicyPath,
+		ReportWriter:          b.reportWriter,
+		HostPolicy:            b.hostPolicy,
+		DefaultMountsFilePath: b.defaultMountsFilePath,
+		BlobFetchers:          b.blobFetchers,
+		UseDockerDaemon:       b.useDockerDaemon,
 	}
 	builder, err := buildah.NewBuilder(b.store, builderOptions)
 	if err != nil {
 		return errors.Wrapf(err, "error creating build container")
 	}
+	if builder.OS() != runtime.GOOS || builder.Architecture() != runtime.GOARCH {
+		if err = b.warn("FROM "+from, "base image is for %s/%s, but we're running on %s/%s", builder.OS(), builder.Architecture(), runtime.GOOS, runtime.GOARCH); err != nil {
+			if err2 := builder.Delete(); err2 != nil {
+				logrus.Debugf("error deleting container for unsuitable base image: %v", err2)
+			}
+			return err
+		}
+	}
 	volumes := map[string]struct{}{}
 	for _, v := range builder.Volumes() {
 		volumes[v] = struct{}{}
@@ -541,12 +831,16 @@ func (b *Executor) Delete() (err error) {
 		err = b.builder.Delete()
 		b.builder = nil
 	}
+	if err2 := b.removeSecrets(); err2 != nil && err == nil {
+		err = errors.Wrapf(err2, "error removing temporary copies of secrets")
+	}
 	return err
 }
 
 // Execute runs each of the steps in the parsed tree, in turn.
 func (b *Executor) Execute(ib *imagebuilder.Builder, node *parser.Node) error {
 	for i, node := range node.Children {
+		b.currentLine = node.StartLine
 		step := ib.Step()
 		if err := step.Resolve(node); err != nil {
 			return errors.Wrapf(err, "error resolving step %+v", *node)
@@ -555,14 +849,28 @@ func (b *Executor) Execute(ib *imagebuilder.Builder, node *parser.Node) error {
 		if !b.quiet {
 			b.log("%s", step.Original)
 		}
+		if err := b.checkStep(step); err != nil {
+			return err
+		}
 		requiresStart := false
 		if i < len(node.Children)-1 {
 			requiresStart = ib.RequiresStart(&parser.Node{Children: node.Children[i+1:]})
 		}
+		b.runFlags = nil
+		if step.Command == command.Run {
+			b.runFlags = step.Flags
+		}
 		err := ib.Run(step, b, requiresStart)
+		b.runFlags = nil
 		if err != nil {
 			return errors.Wrapf(err, "error building at step %+v", *step)
 		}
+		for _, warning := range ib.Warnings {
+			if err := b.warn(step.Original, "%s", strings.TrimSpace(warning)); err != nil {
+				return err
+			}
+		}
+		ib.Warnings = nil
 	}
 	return nil
 }
@@ -610,6 +918,21 @@ func (b *Executor) Commit(ib *imagebuilder.Builder) (err error) {
 	for k, v := range config.Labels {
 		b.builder.SetLabel(k, v)
 	}
+	if b.vcsLabels {
+		info, err := buildah.DetectVCS(b.contextDir)
+		if err != nil {
+			return err
+		}
+		if info == nil {
+			err = b.warn("COMMIT", "%q is not part of a git checkout, not adding revision labels", b.contextDir)
+		} else if info.Dirty {
+			err = b.warn("COMMIT", "the git checkout has uncommitted changes, which revision %s does not include", info.Revision)
+		}
+		if err != nil {
+			return err
+		}
+		b.builder.SetVCSLabels(info)
+	}
 	if imageRef != nil {
 		logName := transports.ImageName(imageRef)
 		logrus.Debugf("COMMIT %q", logName)
@@ -628,8 +951,35 @@ func (b *Executor) Commit(ib *imagebuilder.Builder) (err error) {
 		AdditionalTags:        b.additionalTags,
 		ReportWriter:          b.reportWriter,
 		PreferredManifestType: b.outputFormat,
+		AutoTagFromLabel:      b.autoTagFromLabel,
+	}
+	b.imageRef = imageRef
+	if !b.keepStages {
+		return b.builder.Commit(imageRef, options)
+	}
+	stageName, err := stageImageName(b.output, 0, "")
+	if err != nil {
+		return err
+	}
+	if imageRef.Transport().Name() == is.Transport.Name() {
+		// The stage's image is the output image, so just give it
+		// another name.
+		options.AdditionalTags = append(append([]string{}, b.additionalTags...), stageName)
+		return b.builder.Commit(imageRef, options)
+	}
+	if err = b.builder.Commit(imageRef, options); err != nil {
+		return err
 	}
-	return b.builder.Commit(imageRef, options)
+	// The output image was written somewhere else, so write another copy
+	// to local storage to keep as the stage's image.
+	stageRef, err := is.Transport.ParseStoreReference(b.store, stageName)
+	if err != nil {
+		return errors.Wrapf(err, "error parsing reference for stage image %q", stageName)
+	}
+	logrus.Debugf("COMMIT %q", stageName)
+	options.AdditionalTags = nil
+	options.AutoTagFromLabel = ""
+	return b.builder.Commit(stageRef, options)
 }
 
 // Build takes care of the details of running Prepare/Execute/Commit/Delete
@@ -648,7 +998,10 @@ func (b *Executor) Build(ib *imagebuilder.Builder, node []*parser.Node) (err err
 		return err
 	}
 	defer b.Delete()
-	for _, this := range node {
+	for i, this := range node {
+		if i < len(b.dockerfileNames) {
+			b.currentFile = b.dockerfileNames[i]
+		}
 		if err = b.Execute(ib, this); err != nil {
 			return err
 		}
@@ -661,78 +1014,131 @@ func (b *Executor) Build(ib *imagebuilder.Builder, node []*parser.Node) (err err
 
 // BuildReadClosers parses a set of one or more already-opened Dockerfiles,
 // creates a new Executor, and then runs Prepare/Execute/Commit/Delete over the
-// entire set of instructions.
-func BuildReadClosers(store storage.Store, options BuildOptions, dockerfile ...io.ReadCloser) error {
-	mainFile := dockerfile[0]
-	extraFiles := dockerfile[1:]
+// entire set of instructions.  It returns a list of problems which were noticed
+// during the build, but which did not cause it to fail.
+func BuildReadClosers(store storage.Store, options BuildOptions, dockerfile ...io.ReadCloser) ([]Warning, error) {
 	for _, dfile := range dockerfile {
 		defer dfile.Close()
 	}
-	builder, parsed, err := imagebuilder.NewBuilderForReader(mainFile, options.Args)
+	exec, err := NewExecutor(store, options)
 	if err != nil {
-		return errors.Wrapf(err, "error creating builder")
+		return nil, errors.Wrapf(err, "error creating build executor")
+	}
+	exec.notify(BuildEvent{Type: BuildEventStart, Output: exec.output, Tags: exec.additionalTags})
+	err = exec.parseAndBuild(dockerfile, options.Args)
+	event := BuildEvent{
+		Type:     BuildEventSuccess,
+		Output:   exec.output,
+		Tags:     exec.additionalTags,
+		Warnings: exec.Warnings(),
 	}
-	exec, err := NewExecutor(store, options)
 	if err != nil {
-		return errors.Wrapf(err, "error creating build executor")
+		event.Type = BuildEventFailure
+		event.Error = err.Error()
+	} else if len(exec.notifyTargets) > 0 || exec.ci != nil {
+		event = exec.imageEvent(event)
 	}
-	nodes := []*parser.Node{parsed}
-	for _, extra := range extraFiles {
-		_, parsed, err := imagebuilder.NewBuilderForReader(extra, options.Args)
+	exec.notify(event)
+	exec.reportResult(event)
+	return exec.Warnings(), err
+}
+
+// parseAndBuild parses the Dockerfiles and runs the build.
+func (b *Executor) parseAndBuild(dockerfiles []io.ReadCloser, args map[string]string) error {
+	var builder *imagebuilder.Builder
+	nodes := []*parser.Node{}
+	for i, dfile := range dockerfiles {
+		b.currentFile = dockerfileName(dfile)
+		b.dockerfileNames = append(b.dockerfileNames, b.currentFile)
+		contents, err := ioutil.ReadAll(dfile)
 		if err != nil {
+			return errors.Wrapf(err, "error reading dockerfile")
+		}
+		if _, err = dockerfile.Parse(bytes.NewReader(contents)); err != nil {
+			if perr, ok := err.(*dockerfile.ParseError); ok {
+				b.currentLine = perr.Line
+			}
+			return errors.Wrapf(err, "error parsing dockerfile")
+		}
+		ib, parsed, err := imagebuilder.NewBuilderForReader(bytes.NewReader(contents), args)
+		if err != nil {
+			if i == 0 {
+				return errors.Wrapf(err, "error creating builder")
+			}
 			return errors.Wrapf(err, "error parsing dockerfile")
 		}
+		if i == 0 {
+			builder = ib
+		}
 		nodes = append(nodes, parsed)
 	}
-	return exec.Build(builder, nodes)
+	return b.Build(builder, nodes)
 }
 
-// BuildDockerfiles parses a set of one or more Dockerfiles (which may be
-// URLs), creates a new Executor, and then runs Prepare/Execute/Commit/Delete
-// over the entire set of instructions.
-func BuildDockerfiles(store storage.Store, options BuildOptions, dockerfile ...string) error {
-	var dockerfiles []io.ReadCloser
+// openDockerfiles opens a set of one or more Dockerfiles, which may be URLs or
+// paths relative to the context directory.
+func openDockerfiles(contextDir string, dockerfile ...string) (dockerfiles []io.ReadCloser, err error) {
 	if len(dockerfile) == 0 {
-		return errors.Errorf("error building: no dockerfiles specified")
+		return nil, errors.Errorf("no dockerfiles specified")
 	}
+	defer func() {
+		if err != nil {
+			for _, rc := range dockerfiles {
+				rc.Close()
+			}
+		}
+	}()
 	for _, dfile := range dockerfile {
 		var rc io.ReadCloser
 		if strings.HasPrefix(dfile, "http://") || strings.HasPrefix(dfile, "https://") {
 			logrus.Debugf("reading remote Dockerfile %q", dfile)
 			resp, err := http.Get(dfile)
 			if err != nil {
-				return errors.Wrapf(err, "error getting %q", dfile)
+				return dockerfiles, errors.Wrapf(err, "error getting %q", dfile)
 			}
 			if resp.ContentLength == 0 {
 				resp.Body.Close()
-				return errors.Errorf("no contents in %q", dfile)
+				return dockerfiles, errors.Errorf("no contents in %q", dfile)
 			}
 			rc = resp.Body
 		} else {
 			if !filepath.IsAbs(dfile) {
 				logrus.Debugf("resolving local Dockerfile %q", dfile)
-				dfile = filepath.Join(options.ContextDirectory, dfile)
+				dfile = filepath.Join(contextDir, dfile)
 			}
 			logrus.Debugf("reading local Dockerfile %q", dfile)
 			contents, err := os.Open(dfile)
 			if err != nil {
-				return errors.Wrapf(err, "error reading %q", dfile)
+				return dockerfiles, errors.Wrapf(err, "error reading %q", dfile)
 			}
 			dinfo, err := contents.Stat()
 			if err != nil {
 				contents.Close()
-				return errors.Wrapf(err, "error reading info about %q", dfile)
+				return dockerfiles, errors.Wrapf(err, "error reading info about %q", dfile)
 			}
 			if dinfo.Size() == 0 {
 				contents.Close()
-				return errors.Wrapf(err, "no contents in %q", dfile)
+				return dockerfiles, errors.Errorf("no contents in %q", dfile)
 			}
 			rc = contents
 		}
 		dockerfiles = append(dockerfiles, rc)
 	}
-	if err := BuildReadClosers(store, options, dockerfiles...); err != nil {
-		return errors.Wrapf(err, "error building")
+	return dockerfiles, nil
+}
+
+// BuildDockerfiles parses a set of one or more Dockerfiles (which may be
+// URLs), creates a new Executor, and then runs Prepare/Execute/Commit/Delete
+// over the entire set of instructions.  It returns a list of problems which
+// were noticed during the build, but which did not cause it to fail.
+func BuildDockerfiles(store storage.Store, options BuildOptions, dockerfile ...string) ([]Warning, error) {
+	dockerfiles, err := openDockerfiles(options.ContextDirectory, dockerfile...)
+	if err != nil {
+		return nil, errors.Wrapf(err, "error building")
+	}
+	warnings, err := BuildReadClosers(store, options, dockerfiles...)
+	if err != nil {
+		return warnings, errors.Wrapf(err, "error building")
 	}
-	return nil
+	return warnings, nil
 }
@@ -0,0 +1,111 @@
+package imagebuildah
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/containers/image/manifest"
+	is "github.com/containers/image/storage"
+	"github.com/projectatomic/buildah/util"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// BuildEventStart is the type of the event which is sent when a build
+	// starts.
+	BuildEventStart = "start"
+	// BuildEventSuccess is the type of the event which is sent when a
+	// build finishes and its image has been committed.
+	BuildEventSuccess = "success"
+	// BuildEventFailure is the type of the event which is sent when a
+	// build fails.
+	BuildEventFailure = "failure"
+)
+
+// BuildEvent is the payload which is sent to each of the targets listed in
+// BuildOptions.Notify.
+type BuildEvent struct {
+	// Type is one of BuildEventStart, BuildEventSuccess, or
+	// BuildEventFailure.
+	Type string `json:"type"`
+	// Time is when the event occurred.
+	Time time.Time `json:"time"`
+	// Output is the name of the image which is being built, if it has
+	// one.
+	Output string `json:"output,omitempty"`
+	// Tags is the list of additional tags which are being applied to the
+	// image.
+	Tags []string `json:"tags,omitempty"`
+	// ImageID is the ID of the new image, if it was written to local
+	// storage.
+	ImageID string `json:"image-id,omitempty"`
+	// Digest is the digest of the new image's manifest.
+	Digest string `json:"digest,omitempty"`
+	// Error describes why the build failed.
+	Error string `json:"error,omitempty"`
+	// Warnings is the list of problems which were noticed during the
+	// build, but which did not cause it to fail.
+	Warnings []Warning `json:"warnings,omitempty"`
+}
+
+// checkNotifyTarget verifies that we know how to deliver events to a target,
+// and that the host policy allows it.
+func (b *Executor) checkNotifyTarget(target string) error {
+	host, err := util.ParseNotificationTarget(target)
+	if err != nil {
+		return err
+	}
+	return b.hostPolicy.Check("notify", target, host)
+}
+
+// notify sends an event to each of the notification targets.  Failure to
+// deliver an event is logged, but does not cause the build to fail.
+func (b *Executor) notify(event BuildEvent) {
+	if len(b.notifyTargets) == 0 {
+		return
+	}
+	event.Time = time.Now().UTC()
+	data, err := json.Marshal(&event)
+	if err != nil {
+		logrus.Warnf("error encoding %s event: %v", event.Type, err)
+		return
+	}
+	for _, target := range b.notifyTargets {
+		if err = util.SendNotification(target, data); err != nil {
+			logrus.Warnf("error sending %s event to %q: %v", event.Type, target, err)
+		}
+	}
+}
+
+// imageEvent fills in the ID and digest of the image which was committed, if
+// we can find them.
+func (b *Executor) imageEvent(event BuildEvent) BuildEvent {
+	if b.imageRef == nil {
+		return event
+	}
+	if b.imageRef.Transport().Name() == is.Transport.Name() {
+		if img, err := is.Transport.GetStoreImage(b.store, b.imageRef); err == nil {
+			event.ImageID = img.ID
+		} else {
+			logrus.Debugf("error locating image %q: %v", b.output, err)
+		}
+	}
+	src, err := b.imageRef.NewImageSource(b.systemContext)
+	if err != nil {
+		logrus.Debugf("error reading image %q: %v", b.output, err)
+		return event
+	}
+	defer src.Close()
+	manifestBytes, _, err := src.GetManifest()
+	if err != nil {
+		logrus.Debugf("error reading manifest of image %q: %v", b.output, err)
+		return event
+	}
+	digest, err := manifest.Digest(manifestBytes)
+	if err != nil {
+		logrus.Debugf("error computing digest of image %q: %v", b.output, err)
+		return event
+	}
+	event.Digest = digest.String()
+	return event
+}
@@ -0,0 +1,61 @@
+package imagebuildah
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestNotify(t *testing.T) {
+	received := make(chan BuildEvent, 2)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		event := BuildEvent{}
+		if err := json.NewDecoder(r.Body).Decode(&event); err != nil {
+			t.Errorf("error decoding event: %v", err)
+		}
+		received <- event
+	}))
+	defer server.Close()
+	dir, err := ioutil.TempDir("", "buildah-events")
+	if err != nil {
+		t.Fatalf("error creating temporary directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "events")
+
+	b := &Executor{output: "example", notifyTargets: []string{server.URL, file}}
+	for _, target := range append(b.notifyTargets, "nats://localhost/builds", "") {
+		err := b.checkNotifyTarget(target)
+		if supported := target == server.URL || target == file; supported != (err == nil) {
+			t.Errorf("unexpected result checking %q: %v", target, err)
+		}
+	}
+	b.notify(BuildEvent{Type: BuildEventStart, Output: b.output})
+	b.notify(BuildEvent{Type: BuildEventFailure, Output: b.output, Error: "oops"})
+	for _, expected := range []string{BuildEventStart, BuildEventFailure} {
+		event := <-received
+		if event.Type != expected || event.Output != "example" {
+			t.Errorf("expected %q event for %q, got %#v", expected, "example", event)
+		}
+	}
+	contents, err := ioutil.ReadFile(file)
+	if err != nil {
+		t.Fatalf("error reading events: %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(contents)), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("expected 2 events, got %q", lines)
+	}
+	event := BuildEvent{}
+	if err = json.Unmarshal([]byte(lines[1]), &event); err != nil {
+		t.Fatalf("error decoding event %q: %v", lines[1], err)
+	}
+	if event.Type != BuildEventFailure || event.Error != "oops" {
+		t.Errorf("expected failure event, got %#v", event)
+	}
+}
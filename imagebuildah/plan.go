@@ -0,0 +1,294 @@
+package imagebuildah
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/docker/docker/builder/dockerfile/command"
+	"github.com/opencontainers/go-digest"
+	"github.com/openshift/imagebuilder"
+	"github.com/pkg/errors"
+	"github.com/projectatomic/buildah/dockerfile"
+)
+
+// BuildPlan describes what a build would do, without doing it.
+type BuildPlan struct {
+	// Args is the set of build arguments which are declared in the
+	// Dockerfiles, with the values which they would have.
+	Args map[string]string `json:"args,omitempty"`
+	// UnusedArgs is the list of build arguments which were supplied, but
+	// which aren't declared in the Dockerfiles.
+	UnusedArgs []string `json:"unused-args,omitempty"`
+	// Stages is the list of stages in the build.
+	Stages []PlannedStage `json:"stages"`
+}
+
+// PlannedStage describes one stage of a build.
+type PlannedStage struct {
+	// Index is the position of the stage in the build, counting from 0.
+	Index int `json:"index"`
+	// Name is the name which the stage was given using "FROM image AS
+	// name", if it was given one.
+	Name string `json:"name,omitempty"`
+	// BaseImage is the image which the stage starts from, with build
+	// arguments substituted into it.  If the stage starts from the result
+	// of an earlier stage, it is that stage's name or index.
+	BaseImage string `json:"base-image"`
+	// DependsOn is the list of the indexes of earlier stages whose results
+	// the stage uses, either as its base image or as a source for COPY
+	// --from.
+	DependsOn []int `json:"depends-on,omitempty"`
+	// Steps is the list of instructions in the stage, in the order in which
+	// they would be run.
+	Steps []PlannedStep `json:"steps"`
+}
+
+// PlannedStep describes one instruction in a stage.
+type PlannedStep struct {
+	// Command is the instruction's keyword, in lower case.
+	Command string `json:"command"`
+	// Instruction is the text of the instruction.
+	Instruction string `json:"instruction"`
+	// File and Line are the location of the instruction.
+	File string `json:"file,omitempty"`
+	Line int    `json:"line"`
+	// CacheKey is computed from the stage's base image, this and all of
+	// the stage's earlier instructions, the values of any build arguments
+	// which are in scope, and the contents of any files from the build
+	// context which the instruction uses.  If any of those change, the
+	// key changes, so the step's result can't be reused from an earlier
+	// build.
+	CacheKey string `json:"cache-key"`
+}
+
+// PlanBuild parses a set of one or more Dockerfiles (which may be URLs) and
+// returns a description of what a build using them and the specified options
+// would do, without pulling any images or running any instructions.
+func PlanBuild(options BuildOptions, dockerfiles ...string) (*BuildPlan, error) {
+	readers, err := openDockerfiles(options.ContextDirectory, dockerfiles...)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		for _, rc := range readers {
+			rc.Close()
+		}
+	}()
+	var stages []dockerfile.Stage
+	var globalArgs []dockerfile.Arg
+	files := make(map[*dockerfile.Instruction]string)
+	for i, rc := range readers {
+		parsed, err := dockerfile.Parse(rc)
+		if err != nil {
+			return nil, errors.Wrapf(err, "error parsing %q", dockerfiles[i])
+		}
+		name := dockerfileName(rc)
+		if name == "" {
+			name = dockerfiles[i]
+		}
+		for _, instruction := range parsed.Instructions {
+			files[instruction] = name
+		}
+		if i == 0 {
+			globalArgs = parsed.GlobalArgs
+			stages = parsed.Stages
+			if len(stages) == 0 {
+				return nil, errors.Errorf("no FROM instruction in %q", dockerfiles[0])
+			}
+			continue
+		}
+		// Additional Dockerfiles continue the last stage.
+		last := &stages[len(stages)-1]
+		for _, instruction := range parsed.Instructions {
+			if instruction.Command == command.From {
+				return nil, errors.Errorf("%q: FROM instructions are only allowed in the first dockerfile", dockerfiles[i])
+			}
+			last.Instructions = append(last.Instructions, instruction)
+		}
+	}
+
+	plan := &BuildPlan{
+		Args: make(map[string]string),
+	}
+	declared := make(map[string]bool)
+	argValue := func(arg dockerfile.Arg) string {
+		declared[arg.Name] = true
+		if value, ok := options.Args[arg.Name]; ok {
+			return value
+		}
+		return arg.Value
+	}
+	globalEnv := []string{}
+	for _, arg := range globalArgs {
+		value := argValue(arg)
+		plan.Args[arg.Name] = value
+		globalEnv = append(globalEnv, arg.Name+"="+value)
+	}
+
+	stageNames := make(map[string]int)
+	stageKeys := []digest.Digest{}
+	for i, stage := range stages {
+		planned := PlannedStage{
+			Index: i,
+			Name:  stage.Name,
+		}
+		base, err := imagebuilder.ProcessWord(stage.BaseImage, globalEnv)
+		if err != nil {
+			return nil, errors.Wrapf(err, "error resolving base image %q for stage %d", stage.BaseImage, i)
+		}
+		planned.BaseImage = base
+		key := digest.FromString("FROM " + base)
+		if index, ok := stageNames[base]; ok {
+			planned.DependsOn = append(planned.DependsOn, index)
+			key = stageKeys[index]
+		}
+		env := []string{}
+		for _, instruction := range stage.Instructions {
+			if instruction.Command == command.Arg {
+				for _, word := range instruction.Args {
+					arg := dockerfile.Arg{Name: word}
+					if eq := strings.Index(word, "="); eq >= 0 {
+						arg.Name, arg.Value = word[:eq], word[eq+1:]
+					}
+					value := argValue(arg)
+					plan.Args[arg.Name] = value
+					env = append(env, arg.Name+"="+value)
+				}
+			}
+			var from *int
+			if instruction.Command == command.Copy {
+				for _, flag := range instruction.Flags {
+					if !strings.HasPrefix(flag, "--from=") {
+						continue
+					}
+					name := strings.TrimPrefix(flag, "--from=")
+					index, ok := stageNames[name]
+					if !ok {
+						if n, err := strconv.Atoi(name); err == nil && n >= 0 && n < i {
+							index, ok = n, true
+						}
+					}
+					if ok {
+						from = &index
+						planned.DependsOn = appendUniqueInt(planned.DependsOn, index)
+					}
+				}
+			}
+			if instruction.Command != command.From {
+				key, err = stepCacheKey(key, instruction, env, options.ContextDirectory, from, stageKeys)
+				if err != nil {
+					return nil, err
+				}
+			}
+			planned.Steps = append(planned.Steps, PlannedStep{
+				Command:     instruction.Command,
+				Instruction: instruction.Original,
+				File:        files[instruction],
+				Line:        instruction.StartLine,
+				CacheKey:    key.String(),
+			})
+		}
+		if stage.Name != "" {
+			stageNames[stage.Name] = i
+		}
+		stageNames[strconv.Itoa(i)] = i
+		stageKeys = append(stageKeys, key)
+		plan.Stages = append(plan.Stages, planned)
+	}
+	for name := range options.Args {
+		if !declared[name] {
+			plan.UnusedArgs = append(plan.UnusedArgs, name)
+		}
+	}
+	sort.Strings(plan.UnusedArgs)
+	return plan, nil
+}
+
+func appendUniqueInt(list []int, value int) []int {
+	for _, v := range list {
+		if v == value {
+			return list
+		}
+	}
+	return append(list, value)
+}
+
+// stepCacheKey computes the cache key for an instruction, given the key for
+// the instruction before it.
+func stepCacheKey(parent digest.Digest, instruction *dockerfile.Instruction, env []string, contextDir string, from *int, stageKeys []digest.Digest) (digest.Digest, error) {
+	digester := digest.Canonical.Digester()
+	fmt.Fprintf(digester.Hash(), "%s\n%s\n", parent, instruction.Original)
+	sorted := append([]string{}, env...)
+	sort.Strings(sorted)
+	for _, e := range sorted {
+		fmt.Fprintf(digester.Hash(), "%s\n", e)
+	}
+	if (instruction.Command == command.Add || instruction.Command == command.Copy) && len(instruction.Args) > 1 {
+		if from != nil {
+			// The content comes from another stage, so its key
+			// stands in for the content.
+			fmt.Fprintf(digester.Hash(), "from %s\n", stageKeys[*from])
+		} else {
+			for _, src := range instruction.Args[:len(instruction.Args)-1] {
+				if err := hashContextSource(digester.Hash(), contextDir, src); err != nil {
+					return "", err
+				}
+			}
+		}
+	}
+	return digester.Digest(), nil
+}
+
+// hashContextSource adds the names, permissions, and contents of the files in
+// the build context which match a COPY or ADD source to a hash.  Sources which
+// are URLs are only hashed by name, since fetching them is part of the build.
+func hashContextSource(w io.Writer, contextDir, src string) error {
+	fmt.Fprintf(w, "source %s\n", src)
+	if strings.HasPrefix(src, "http://") || strings.HasPrefix(src, "https://") {
+		return nil
+	}
+	matches, err := filepath.Glob(filepath.Join(contextDir, filepath.Clean("/"+src)))
+	if err != nil {
+		return errors.Wrapf(err, "error processing source %q", src)
+	}
+	for _, match := range matches {
+		err = filepath.Walk(match, func(path string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			rel, err := filepath.Rel(contextDir, path)
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(w, "%s %s %d\n", rel, info.Mode(), info.Size())
+			switch {
+			case info.Mode()&os.ModeSymlink != 0:
+				target, err := os.Readlink(path)
+				if err != nil {
+					return err
+				}
+				fmt.Fprintf(w, "-> %s\n", target)
+			case info.Mode().IsRegular():
+				f, err := os.Open(path)
+				if err != nil {
+					return err
+				}
+				_, err = io.Copy(w, f)
+				f.Close()
+				if err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return errors.Wrapf(err, "error reading source %q", src)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,82 @@
+package imagebuildah
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestPlanBuild(t *testing.T) {
+	dir, err := ioutil.TempDir("", "buildah-plan")
+	if err != nil {
+		t.Fatalf("error creating temporary directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	dockerfile := `ARG BASE=alpine
+FROM ${BASE} AS builder
+ARG VERSION=1.0
+COPY src /src
+RUN make -C /src
+FROM busybox
+COPY --from=builder /src/out /usr/bin/
+`
+	if err = ioutil.WriteFile(filepath.Join(dir, "Dockerfile"), []byte(dockerfile), 0644); err != nil {
+		t.Fatalf("error writing Dockerfile: %v", err)
+	}
+	if err = os.Mkdir(filepath.Join(dir, "src"), 0755); err != nil {
+		t.Fatalf("error creating source directory: %v", err)
+	}
+	source := filepath.Join(dir, "src", "main.c")
+	if err = ioutil.WriteFile(source, []byte("int main() { return 0; }\n"), 0644); err != nil {
+		t.Fatalf("error writing source: %v", err)
+	}
+	options := BuildOptions{
+		ContextDirectory: dir,
+		Args:             map[string]string{"BASE": "fedora", "UNUSED": "x"},
+	}
+	plan, err := PlanBuild(options, "Dockerfile")
+	if err != nil {
+		t.Fatalf("error planning build: %v", err)
+	}
+	if !reflect.DeepEqual(plan.Args, map[string]string{"BASE": "fedora", "VERSION": "1.0"}) {
+		t.Errorf("unexpected args %#v", plan.Args)
+	}
+	if !reflect.DeepEqual(plan.UnusedArgs, []string{"UNUSED"}) {
+		t.Errorf("unexpected unused args %#v", plan.UnusedArgs)
+	}
+	if len(plan.Stages) != 2 || plan.Stages[0].BaseImage != "fedora" || plan.Stages[0].Name != "builder" {
+		t.Fatalf("unexpected stages %#v", plan.Stages)
+	}
+	if !reflect.DeepEqual(plan.Stages[1].DependsOn, []int{0}) {
+		t.Errorf("expected second stage to depend on the first, got %#v", plan.Stages[1].DependsOn)
+	}
+	if len(plan.Stages[0].Steps) != 4 || plan.Stages[0].Steps[2].Line != 4 {
+		t.Fatalf("unexpected steps %#v", plan.Stages[0].Steps)
+	}
+
+	// Changing a source file should change the keys of the step which
+	// uses it, every step after it, and the steps of stages which use
+	// its stage's result, but not the steps before it.
+	if err = ioutil.WriteFile(source, []byte("int main() { return 1; }\n"), 0644); err != nil {
+		t.Fatalf("error writing source: %v", err)
+	}
+	replan, err := PlanBuild(options, "Dockerfile")
+	if err != nil {
+		t.Fatalf("error planning build: %v", err)
+	}
+	for i, step := range plan.Stages[0].Steps {
+		changed := step.CacheKey != replan.Stages[0].Steps[i].CacheKey
+		if changed != (i >= 2) {
+			t.Errorf("unexpected change=%v in cache key for step %q", changed, step.Instruction)
+		}
+	}
+	last := len(plan.Stages[1].Steps) - 1
+	if plan.Stages[1].Steps[last].CacheKey == replan.Stages[1].Steps[last].CacheKey {
+		t.Errorf("expected cache key for %q to change", plan.Stages[1].Steps[last].Instruction)
+	}
+	if plan.Stages[1].Steps[0].CacheKey != replan.Stages[1].Steps[0].CacheKey {
+		t.Errorf("expected cache key for %q not to change", plan.Stages[1].Steps[0].Instruction)
+	}
+}
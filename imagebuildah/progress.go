@@ -0,0 +1,192 @@
+package imagebuildah
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// ProgressPlain reports progress by printing each step as it is
+	// started.  It is the default.
+	ProgressPlain = "plain"
+	// ProgressCI reports progress in a form which CI systems understand:
+	// each step's output is placed in a collapsible group, problems are
+	// reported as annotations which point to the relevant line of the
+	// Dockerfile, and a summary is produced at the end of the build.
+	ProgressCI = "ci"
+)
+
+// ciReporter formats progress messages for CI systems.  GitLab CI's syntax is
+// used when $GITLAB_CI is set, and GitHub Actions' syntax is used otherwise.
+type ciReporter struct {
+	out     io.Writer
+	gitlab  bool
+	group   int
+	inGroup bool
+}
+
+func newCIReporter(out io.Writer) *ciReporter {
+	return &ciReporter{
+		out:    out,
+		gitlab: os.Getenv("GITLAB_CI") == "true",
+	}
+}
+
+// escapeGitHubData escapes a value for use in a GitHub Actions workflow
+// command.  Values of properties also need to have colons and commas escaped.
+func escapeGitHubData(value string, property bool) string {
+	value = strings.Replace(value, "%", "%25", -1)
+	value = strings.Replace(value, "\r", "%0D", -1)
+	value = strings.Replace(value, "\n", "%0A", -1)
+	if property {
+		value = strings.Replace(value, ":", "%3A", -1)
+		value = strings.Replace(value, ",", "%2C", -1)
+	}
+	return value
+}
+
+// startGroup ends the current group, if there is one, and starts a new
+// collapsible group with the specified title.
+func (c *ciReporter) startGroup(title string) {
+	c.endGroup()
+	c.group++
+	c.inGroup = true
+	title = strings.Replace(title, "\n", " ", -1)
+	if c.gitlab {
+		fmt.Fprintf(c.out, "\x1b[0Ksection_start:%d:buildah_step_%d[collapsed=true]\r\x1b[0K%s\n", time.Now().Unix(), c.group, title)
+		return
+	}
+	fmt.Fprintf(c.out, "::group::%s\n", escapeGitHubData(title, false))
+}
+
+// endGroup ends the current group, if there is one.
+func (c *ciReporter) endGroup() {
+	if !c.inGroup {
+		return
+	}
+	c.inGroup = false
+	if c.gitlab {
+		fmt.Fprintf(c.out, "\x1b[0Ksection_end:%d:buildah_step_%d\r\x1b[0K\n", time.Now().Unix(), c.group)
+		return
+	}
+	fmt.Fprintf(c.out, "::endgroup::\n")
+}
+
+// annotate reports a problem, which is either an "error" or a "warning", at a
+// location in a Dockerfile.  The file can be empty, and the line can be 0, if
+// the location isn't known.
+func (c *ciReporter) annotate(level, file string, line int, message string) {
+	if c.gitlab {
+		location := ""
+		if file != "" {
+			location = file + ":"
+			if line > 0 {
+				location += fmt.Sprintf("%d:", line)
+			}
+			location += " "
+		}
+		color := "31"
+		if level == "warning" {
+			color = "33"
+		}
+		fmt.Fprintf(c.out, "\x1b[%s;1m%s\x1b[0m: %s%s\n", color, strings.ToUpper(level), location, message)
+		return
+	}
+	properties := []string{}
+	if file != "" {
+		properties = append(properties, "file="+escapeGitHubData(file, true))
+		if line > 0 {
+			properties = append(properties, fmt.Sprintf("line=%d", line))
+		}
+	}
+	fmt.Fprintf(c.out, "::%s %s::%s\n", level, strings.Join(properties, ","), escapeGitHubData(message, false))
+}
+
+// summarize reports the outcome of a build, both in a final group in the log
+// and, when running under GitHub Actions, in the job's summary.
+func (c *ciReporter) summarize(event BuildEvent) {
+	var summary bytes.Buffer
+	result := "succeeded"
+	if event.Type == BuildEventFailure {
+		result = "failed"
+	}
+	name := event.Output
+	if name == "" {
+		name = "image"
+	}
+	fmt.Fprintf(&summary, "### Build of %s %s\n\n", name, result)
+	if len(event.Tags) > 0 {
+		fmt.Fprintf(&summary, "- Tags: %s\n", strings.Join(event.Tags, ", "))
+	}
+	if event.ImageID != "" {
+		fmt.Fprintf(&summary, "- Image ID: `%s`\n", event.ImageID)
+	}
+	if event.Digest != "" {
+		fmt.Fprintf(&summary, "- Digest: `%s`\n", event.Digest)
+	}
+	if event.Error != "" {
+		fmt.Fprintf(&summary, "- Error: %s\n", event.Error)
+	}
+	if len(event.Warnings) > 0 {
+		fmt.Fprintf(&summary, "\n#### Warnings\n\n")
+		for _, warning := range event.Warnings {
+			fmt.Fprintf(&summary, "- %s\n", warning)
+		}
+	}
+	c.startGroup("Build summary")
+	c.out.Write(summary.Bytes())
+	c.endGroup()
+	if path := os.Getenv("GITHUB_STEP_SUMMARY"); path != "" && !c.gitlab {
+		f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+		if err != nil {
+			logrus.Warnf("error opening job summary %q: %v", path, err)
+			return
+		}
+		if _, err = f.Write(append(summary.Bytes(), '\n')); err != nil {
+			logrus.Warnf("error writing job summary %q: %v", path, err)
+		}
+		f.Close()
+	}
+}
+
+// dockerfileName returns the name which we use to refer to a Dockerfile in
+// annotations, relative to the current directory if possible, since CI
+// systems expect paths relative to the top of the checkout, which is where
+// builds are usually started.
+func dockerfileName(dockerfile io.Reader) string {
+	named, ok := dockerfile.(interface {
+		Name() string
+	})
+	if !ok {
+		return ""
+	}
+	name := named.Name()
+	if cwd, err := os.Getwd(); err == nil && filepath.IsAbs(name) {
+		if rel, err := filepath.Rel(cwd, name); err == nil && !strings.HasPrefix(rel, "..") {
+			return rel
+		}
+	}
+	return name
+}
+
+// reportResult ends the last step's group, annotates the build's error, if it
+// failed, and summarizes its result.
+func (b *Executor) reportResult(event BuildEvent) {
+	if b.ci == nil {
+		return
+	}
+	b.flushOutput()
+	b.ci.endGroup()
+	if event.Type == BuildEventFailure {
+		b.ci.annotate("error", b.currentFile, b.currentLine, event.Error)
+	}
+	b.ci.summarize(event)
+	b.flushOutput()
+}
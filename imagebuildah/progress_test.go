@@ -0,0 +1,19 @@
+package imagebuildah
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestCIReporter(t *testing.T) {
+	var buf bytes.Buffer
+	c := &ciReporter{out: &buf}
+	c.startGroup("STEP 1: FROM alpine")
+	c.startGroup("STEP 2: RUN false")
+	c.annotate("error", "build/Dockerfile", 2, "100% wrong\nreally")
+	c.endGroup()
+	expected := "::group::STEP 1: FROM alpine\n::endgroup::\n::group::STEP 2: RUN false\n::error file=build/Dockerfile,line=2::100%25 wrong%0Areally\n::endgroup::\n"
+	if buf.String() != expected {
+		t.Errorf("expected %q, got %q", expected, buf.String())
+	}
+}
@@ -0,0 +1,150 @@
+package imagebuildah
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/opencontainers/runtime-spec/specs-go"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// secretsDir is the directory in the build container under which
+	// secrets are made available to RUN instructions.
+	secretsDir = "/run/secrets"
+	// secretMask is the text which replaces the values of secrets in
+	// output.
+	secretMask = "********"
+)
+
+// maskingWriter replaces any occurrences of the values of secrets with
+// secretMask before passing the data on to another writer.  Data which might
+// be the start of a secret is held back until we know whether or not it is.
+type maskingWriter struct {
+	writer  io.Writer
+	secrets [][]byte
+	pending []byte
+}
+
+// newMaskingWriter wraps writer in a maskingWriter which will hide the
+// specified values.
+func newMaskingWriter(writer io.Writer, secrets map[string]string) *maskingWriter {
+	m := &maskingWriter{writer: writer}
+	for _, value := range secrets {
+		if value != "" {
+			m.secrets = append(m.secrets, []byte(value))
+		}
+	}
+	// Mask longer values first, in case one secret contains another.
+	sort.Slice(m.secrets, func(i, j int) bool { return len(m.secrets[i]) > len(m.secrets[j]) })
+	return m
+}
+
+// mask replaces occurrences of secrets in data.  If final is false, it stops
+// at the first point where the rest of data could be the start of a secret,
+// and also returns what's left.
+func (m *maskingWriter) mask(data []byte, final bool) (masked, rest []byte) {
+	masked = make([]byte, 0, len(data))
+	i := 0
+scan:
+	for i < len(data) {
+		if !final {
+			// Wait for more data if this could still become the
+			// start of a longer secret than any which match now.
+			for _, secret := range m.secrets {
+				if len(data)-i < len(secret) && bytes.HasPrefix(secret, data[i:]) {
+					break scan
+				}
+			}
+		}
+		for _, secret := range m.secrets {
+			if bytes.HasPrefix(data[i:], secret) {
+				masked = append(masked, secretMask...)
+				i += len(secret)
+				continue scan
+			}
+		}
+		masked = append(masked, data[i])
+		i++
+	}
+	return masked, data[i:]
+}
+
+// Write masks and passes on everything that it can.
+func (m *maskingWriter) Write(p []byte) (int, error) {
+	masked, rest := m.mask(append(m.pending, p...), false)
+	m.pending = append([]byte{}, rest...)
+	if _, err := m.writer.Write(masked); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// Flush passes on any data which we've been holding back.
+func (m *maskingWriter) Flush() error {
+	if len(m.pending) == 0 {
+		return nil
+	}
+	masked, _ := m.mask(m.pending, true)
+	m.pending = nil
+	_, err := m.writer.Write(masked)
+	return err
+}
+
+// flushOutput flushes the writers which we use for output, if they're
+// masking secrets.
+func (b *Executor) flushOutput() {
+	for _, w := range []io.Writer{b.out, b.err} {
+		if m, ok := w.(*maskingWriter); ok {
+			if err := m.Flush(); err != nil {
+				logrus.Debugf("error flushing output: %v", err)
+			}
+		}
+	}
+}
+
+// secretMounts writes the build's secrets to a temporary directory, if we
+// haven't already done so, and returns a list of read-only bind mounts which
+// will make them available to RUN instructions.
+func (b *Executor) secretMounts() ([]specs.Mount, error) {
+	if len(b.secrets) == 0 {
+		return nil, nil
+	}
+	if b.secretsDir == "" {
+		dir, err := ioutil.TempDir("", "buildah-secrets")
+		if err != nil {
+			return nil, errors.Wrapf(err, "error creating temporary directory for secrets")
+		}
+		b.secretsDir = dir
+		for id, value := range b.secrets {
+			if err = ioutil.WriteFile(filepath.Join(dir, id), []byte(value), 0400); err != nil {
+				return nil, errors.Wrapf(err, "error writing secret %q", id)
+			}
+		}
+	}
+	mounts := []specs.Mount{}
+	for id := range b.secrets {
+		mounts = append(mounts, specs.Mount{
+			Source:      filepath.Join(b.secretsDir, id),
+			Destination: filepath.Join(secretsDir, id),
+			Type:        "bind",
+			Options:     []string{"bind", "ro"},
+		})
+	}
+	return mounts, nil
+}
+
+// removeSecrets removes the temporary copies of the build's secrets.
+func (b *Executor) removeSecrets() error {
+	if b.secretsDir == "" {
+		return nil
+	}
+	err := os.RemoveAll(b.secretsDir)
+	b.secretsDir = ""
+	return err
+}
@@ -0,0 +1,23 @@
+package imagebuildah
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestMaskingWriter(t *testing.T) {
+	var buf bytes.Buffer
+	m := newMaskingWriter(&buf, map[string]string{"token": "s3cr3t", "short": "s3c", "empty": ""})
+	for _, chunk := range []string{"value is s3", "cr", "3t, prefix s3c", "r, end s3"} {
+		if _, err := m.Write([]byte(chunk)); err != nil {
+			t.Fatalf("error writing %q: %v", chunk, err)
+		}
+	}
+	if err := m.Flush(); err != nil {
+		t.Fatalf("error flushing: %v", err)
+	}
+	expected := "value is ********, prefix ********r, end s3"
+	if buf.String() != expected {
+		t.Errorf("expected %q, got %q", expected, buf.String())
+	}
+}
@@ -7,8 +7,11 @@ import (
 	"os"
 	"os/exec"
 	"path"
+	"strconv"
 	"strings"
 
+	"github.com/containers/image/docker/reference"
+	"github.com/containers/image/transports/alltransports"
 	"github.com/containers/storage/pkg/chrootarchive"
 	"github.com/pkg/errors"
 	"github.com/projectatomic/buildah"
@@ -88,6 +91,39 @@ func TempDirForURL(dir, prefix, url string) (name string, subdir string, err err
 	return "", "", errors.Errorf("unreachable code reached")
 }
 
+// stageImageName computes the name under which the result of a build stage is
+// kept in local storage: the output image's repository name with "/stage"
+// appended, tagged with the stage's alias if it has one, or with its index if
+// it doesn't.
+func stageImageName(output string, stage int, alias string) (string, error) {
+	if output == "" {
+		return "", errors.Errorf("keeping stage images requires a name for the output image")
+	}
+	var named reference.Named
+	if ref, err := alltransports.ParseImageName(output); err == nil && ref.DockerReference() != nil {
+		named = ref.DockerReference()
+	} else {
+		parsed, err := reference.ParseNormalizedNamed(output)
+		if err != nil {
+			return "", errors.Wrapf(err, "error parsing output image name %q", output)
+		}
+		named = parsed
+	}
+	repo, err := reference.ParseNormalizedNamed(named.Name() + "/stage")
+	if err != nil {
+		return "", errors.Wrapf(err, "error computing stage image name for %q", output)
+	}
+	tag := alias
+	if tag == "" {
+		tag = strconv.Itoa(stage)
+	}
+	tagged, err := reference.WithTag(repo, tag)
+	if err != nil {
+		return "", errors.Wrapf(err, "error computing stage image name for %q using tag %q", output, tag)
+	}
+	return tagged.String(), nil
+}
+
 // InitReexec is a wrapper for buildah.InitReexec().  It should be called at
 // the start of main(), and if it returns true, main() should return
 // immediately.
@@ -14,6 +14,7 @@ import (
 	"github.com/opencontainers/selinux/go-selinux/label"
 	"github.com/openshift/imagebuilder"
 	"github.com/pkg/errors"
+	"github.com/projectatomic/buildah/util"
 	"github.com/sirupsen/logrus"
 )
 
@@ -26,6 +27,10 @@ const (
 	// can't find one in the local Store, in order to generate a source
 	// reference for the image that we can then copy to the local Store.
 	DefaultTransport = "docker://"
+
+	// maxContainerNameAttempts is the number of generated names which we
+	// try to create a container with before giving up.
+	maxContainerNameAttempts = 10
 )
 
 func reserveSELinuxLabels(store storage.Store, id string) error {
@@ -58,6 +63,48 @@ func reserveSELinuxLabels(store storage.Store, id string) error {
 	return nil
 }
 
+// createContainer creates a container with the specified name, or, if the
+// name was generated, with the name plus the lowest numeric suffix which isn't
+// already in use, and returns the container and the name it was given.  The
+// check and the creation are made atomic with respect to other buildah
+// processes using util.LockStore, and if another tool takes the name in the
+// meantime, a different name is tried.
+func createContainer(store storage.Store, name string, generated bool, imageID string, options *storage.ContainerOptions) (*storage.Container, string, error) {
+	unlock, err := util.LockStore(store)
+	if err != nil {
+		return nil, "", err
+	}
+	defer unlock()
+	suffix := 1
+	for attempt := 1; ; attempt++ {
+		candidate := name
+		if generated {
+			for {
+				if suffix > 1 {
+					candidate = fmt.Sprintf("%s-%d", name, suffix)
+				}
+				_, err = store.Container(candidate)
+				if errors.Cause(err) == storage.ErrContainerUnknown {
+					break
+				}
+				if err != nil {
+					return nil, "", errors.Wrapf(err, "error checking if container name %q is in use", candidate)
+				}
+				suffix++
+			}
+		}
+		container, err := store.CreateContainer("", []string{candidate}, imageID, "", "", options)
+		if err == nil {
+			return container, candidate, nil
+		}
+		if !generated || errors.Cause(err) != storage.ErrDuplicateName || attempt >= maxContainerNameAttempts {
+			return nil, "", errors.Wrapf(err, "error creating container")
+		}
+		logrus.Debugf("container name %q was taken while we were creating a container, trying again", candidate)
+		suffix++
+	}
+}
+
 func newBuilder(store storage.Store, options BuilderOptions) (*Builder, error) {
 	var ref types.ImageReference
 	var img *storage.Image
@@ -149,7 +196,6 @@ func newBuilder(store storage.Store, options BuilderOptions) (*Builder, error) {
 	if options.Container != "" {
 		name = options.Container
 	} else {
-		var err2 error
 		if image != "" {
 			prefix := image
 			s := strings.Split(prefix, "/")
@@ -166,21 +212,11 @@ func newBuilder(store storage.Store, options BuilderOptions) (*Builder, error) {
 			}
 			name = prefix + "-" + name
 		}
-		suffix := 1
-		tmpName := name
-		for errors.Cause(err2) != storage.ErrContainerUnknown {
-			_, err2 = store.Container(tmpName)
-			if err2 == nil {
-				suffix++
-				tmpName = fmt.Sprintf("%s-%d", name, suffix)
-			}
-		}
-		name = tmpName
 	}
 	coptions := storage.ContainerOptions{}
-	container, err := store.CreateContainer("", []string{name}, imageID, "", "", &coptions)
+	container, name, err := createContainer(store, name, options.Container == "", imageID, &coptions)
 	if err != nil {
-		return nil, errors.Wrapf(err, "error creating container")
+		return nil, err
 	}
 
 	defer func() {
@@ -204,6 +240,7 @@ func newBuilder(store storage.Store, options BuilderOptions) (*Builder, error) {
 		Type:                  containerType,
 		FromImage:             image,
 		FromImageID:           imageID,
+		FromImageDigest:       manifestDigest(manifest),
 		Config:                config,
 		Manifest:              manifest,
 		Container:             name,
@@ -213,6 +250,7 @@ func newBuilder(store storage.Store, options BuilderOptions) (*Builder, error) {
 		ProcessLabel:          processLabel,
 		MountLabel:            mountLabel,
 		DefaultMountsFilePath: options.DefaultMountsFilePath,
+		HostPolicy:            options.HostPolicy,
 	}
 
 	if options.Mount {
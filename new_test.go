@@ -0,0 +1,45 @@
+package buildah
+
+import (
+	"testing"
+
+	is "github.com/containers/image/storage"
+	"github.com/projectatomic/buildah/util"
+)
+
+func TestNewBuilderScratchEphemeral(t *testing.T) {
+	store, remove, err := util.NewEphemeralStore()
+	if err != nil {
+		t.Fatalf("error creating ephemeral store: %v", err)
+	}
+	defer func() {
+		if err := remove(); err != nil {
+			t.Errorf("error removing ephemeral store: %v", err)
+		}
+	}()
+	b, err := NewBuilder(store, BuilderOptions{FromImage: BaseImageFakeName})
+	if err != nil {
+		t.Fatalf("error creating builder: %v", err)
+	}
+	if b.Container != "working-container" {
+		t.Errorf("expected container to be named %q, got %q", "working-container", b.Container)
+	}
+	b.SetCmd([]string{"/bin/true"})
+	dest, err := is.Transport.ParseStoreReference(store, "ephemeral-test")
+	if err != nil {
+		t.Fatalf("error parsing image name: %v", err)
+	}
+	if err = b.Commit(dest, CommitOptions{SignaturePolicyPath: "tests/policy.json"}); err != nil {
+		t.Fatalf("error committing image: %v", err)
+	}
+	if err = b.Delete(); err != nil {
+		t.Fatalf("error deleting container: %v", err)
+	}
+	img, err := util.FindImage(store, "ephemeral-test")
+	if err != nil {
+		t.Fatalf("error finding committed image: %v", err)
+	}
+	if len(img.Names) != 1 {
+		t.Errorf("expected committed image to have one name, got %v", img.Names)
+	}
+}
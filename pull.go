@@ -78,6 +78,10 @@ func pullImage(store storage.Store, options BuilderOptions, sc *types.SystemCont
 		srcRef = srcRef2
 	}
 
+	if err = options.HostPolicy.CheckReference("pull", srcRef); err != nil {
+		return nil, err
+	}
+
 	destName, err := localImageNameForReference(store, srcRef)
 	if err != nil {
 		return nil, errors.Wrapf(err, "error computing local image name for %q", transports.ImageName(srcRef))
@@ -107,6 +111,18 @@ func pullImage(store storage.Store, options BuilderOptions, sc *types.SystemCont
 		}
 	}()
 
+	if options.UseDockerDaemon && options.PullPolicy != PullAlways {
+		err = copyFromDockerDaemon(policyContext, destRef, srcRef, options)
+		if err == nil {
+			return destRef, nil
+		}
+		logrus.Debugf("not using a copy of %q from the Docker daemon: %v", transports.ImageName(srcRef), err)
+	}
+
+	if len(options.BlobFetchers) > 0 {
+		srcRef = &fetchingReference{ImageReference: srcRef, fetchers: options.BlobFetchers}
+	}
+
 	logrus.Debugf("copying %q to %q", spec, name)
 
 	err = cp.Image(policyContext, destRef, srcRef, getCopyOptions(options.ReportWriter, options.SystemContext, nil, ""))
@@ -2,10 +2,12 @@ package buildah
 
 import (
 	"encoding/json"
+	"io"
 	"io/ioutil"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"sort"
 	"strings"
 
 	"github.com/containers/storage/pkg/ioutils"
@@ -22,6 +24,19 @@ const (
 	DefaultWorkingDir = "/"
 	// DefaultRuntime is the default command to use to run the container.
 	DefaultRuntime = "runc"
+	// DefaultPATH is the value of $PATH which we use if neither the image
+	// nor the caller supply one.
+	DefaultPATH = "/usr/local/sbin:/usr/local/bin:/usr/sbin:/usr/bin:/sbin:/bin"
+	// initDestination is where we mount an init binary in the container
+	// when we've been asked to run one.
+	initDestination = "/dev/init"
+)
+
+var (
+	// DefaultInitBinaries is the list of statically-linked init binaries
+	// which we look for in $PATH if we've been asked to run an init, but
+	// not told which one to use.
+	DefaultInitBinaries = []string{"catatonit", "tini-static", "docker-init"}
 )
 
 const (
@@ -64,6 +79,19 @@ type RunOptions struct {
 	// decision can be overridden by specifying either WithTerminal or
 	// WithoutTerminal.
 	Terminal int
+	// Init causes a minimal init process to be run as PID 1 in the
+	// container, to forward signals to the command and reap any zombie
+	// processes which it leaves behind.
+	Init bool
+	// InitPath is the location of the init binary to use.  If not set,
+	// the first of DefaultInitBinaries which can be found is used.
+	InitPath string
+	// Devices are additional device nodes which we want to provide.
+	Devices []specs.LinuxDevice
+	// Stdout and Stderr are the writers to which the command's output
+	// should be sent.  If not set, os.Stdout and os.Stderr are used.
+	Stdout io.Writer
+	Stderr io.Writer
 }
 
 func (b *Builder) setupMounts(mountPoint string, spec *specs.Spec, optionMounts []specs.Mount, bindFiles, volumes []string) error {
@@ -107,11 +135,14 @@ func (b *Builder) setupMounts(mountPoint string, spec *specs.Spec, optionMounts
 	}
 
 	// Add secrets mounts
-	mountsFiles := []string{OverrideMountsFile, b.DefaultMountsFilePath}
+	mountsFiles := []string{OverrideMountsFile, userMountsFilePath(), b.DefaultMountsFilePath}
 	for _, file := range mountsFiles {
+		if file == "" {
+			continue
+		}
 		secretMounts, err := secretMounts(file, b.MountLabel, cdir)
 		if err != nil {
-			logrus.Warn("error mounting secrets, skipping...")
+			logrus.Warnf("error mounting secrets listed in %q, skipping: %v", file, err)
 		}
 		for _, mount := range secretMounts {
 			if haveMount(mount.Destination) {
@@ -148,11 +179,69 @@ func (b *Builder) setupMounts(mountPoint string, spec *specs.Spec, optionMounts
 			Options:     []string{"bind"},
 		})
 	}
+	// Make sure that mounts which are nested under other mounts, like
+	// individual secrets under a directory of default secrets, aren't
+	// hidden by the mounts that they're nested under.
+	sort.SliceStable(mounts, func(i, j int) bool {
+		return mountDepth(mounts[i].Destination) < mountDepth(mounts[j].Destination)
+	})
 	// Set the list in the spec.
 	spec.Mounts = mounts
 	return nil
 }
 
+// mountDepth returns the number of path components in a mount's destination.
+func mountDepth(destination string) int {
+	cleaned := filepath.Clean(string(filepath.Separator) + destination)
+	if cleaned == string(filepath.Separator) {
+		return 0
+	}
+	return strings.Count(cleaned, string(filepath.Separator))
+}
+
+// findInit locates the init binary which we'll run in the container.
+func findInit(initPath string) (string, error) {
+	if initPath != "" {
+		if _, err := os.Stat(initPath); err != nil {
+			return "", errors.Wrapf(err, "error locating init binary %q", initPath)
+		}
+		return initPath, nil
+	}
+	for _, candidate := range DefaultInitBinaries {
+		if path, err := exec.LookPath(candidate); err == nil {
+			return path, nil
+		}
+	}
+	return "", errors.Errorf("unable to find an init binary (one of %s) in $PATH", strings.Join(DefaultInitBinaries, ", "))
+}
+
+// findExecutable checks that command can be found in one of the directories
+// listed in path, relative to the container's root filesystem, so that we can
+// produce a clearer error than the runtime would if it can't be found.
+func findExecutable(mountPoint, command, path string) error {
+	if strings.Contains(command, "/") {
+		return nil
+	}
+	for _, dir := range filepath.SplitList(path) {
+		if dir == "" {
+			dir = "."
+		}
+		if !filepath.IsAbs(dir) {
+			// Relative entries depend on the working directory,
+			// so leave it up to the runtime to find things there.
+			return nil
+		}
+		candidate, err := resolveInRoot(mountPoint, filepath.Join(dir, command))
+		if err != nil {
+			return nil
+		}
+		if finfo, err := os.Stat(candidate); err == nil && !finfo.IsDir() {
+			return nil
+		}
+	}
+	return errors.Errorf("executable %q not found in $PATH (%s) in container", command, path)
+}
+
 // Run runs the specified command in the container's root filesystem.
 func (b *Builder) Run(command []string, options RunOptions) error {
 	var user specs.User
@@ -168,12 +257,20 @@ func (b *Builder) Run(command []string, options RunOptions) error {
 	}()
 	g := generate.New()
 
+	searchPath := DefaultPATH
 	for _, envSpec := range append(b.Env(), options.Env...) {
 		env := strings.SplitN(envSpec, "=", 2)
 		if len(env) > 1 {
+			if env[0] == "PATH" {
+				if env[1] == "" {
+					continue
+				}
+				searchPath = env[1]
+			}
 			g.AddProcessEnv(env[0], env[1])
 		}
 	}
+	g.AddProcessEnv("PATH", searchPath)
 	if len(command) > 0 {
 		g.SetProcessArgs(command)
 	} else {
@@ -230,6 +327,17 @@ func (b *Builder) Run(command []string, options RunOptions) error {
 	} {
 		g.AddLinuxReadonlyPaths(rp)
 	}
+	for _, device := range options.Devices {
+		g.AddDevice(device)
+		major, minor := device.Major, device.Minor
+		g.Spec().Linux.Resources.Devices = append(g.Spec().Linux.Resources.Devices, specs.LinuxDeviceCgroup{
+			Allow:  true,
+			Type:   device.Type,
+			Major:  &major,
+			Minor:  &minor,
+			Access: "rwm",
+		})
+	}
 	g.SetRootPath(mountPoint)
 	switch options.Terminal {
 	case DefaultTerminal:
@@ -261,9 +369,29 @@ func (b *Builder) Run(command []string, options RunOptions) error {
 	if err = os.MkdirAll(filepath.Join(mountPoint, spec.Process.Cwd), 0755); err != nil {
 		return errors.Wrapf(err, "error ensuring working directory %q exists", spec.Process.Cwd)
 	}
+	if len(spec.Process.Args) == 0 || spec.Process.Args[0] == "" {
+		return errors.Errorf("no command specified")
+	}
+	if err = findExecutable(mountPoint, spec.Process.Args[0], searchPath); err != nil {
+		return err
+	}
+	optionMounts := options.Mounts
+	if options.Init {
+		initPath, err := findInit(options.InitPath)
+		if err != nil {
+			return err
+		}
+		optionMounts = append(append([]specs.Mount{}, optionMounts...), specs.Mount{
+			Source:      initPath,
+			Destination: initDestination,
+			Type:        "bind",
+			Options:     []string{"bind", "ro"},
+		})
+		spec.Process.Args = append([]string{initDestination, "--"}, spec.Process.Args...)
+	}
 
 	bindFiles := []string{"/etc/hosts", "/etc/resolv.conf"}
-	err = b.setupMounts(mountPoint, spec, options.Mounts, bindFiles, b.Volumes())
+	err = b.setupMounts(mountPoint, spec, optionMounts, bindFiles, b.Volumes())
 	if err != nil {
 		return errors.Wrapf(err, "error resolving mountpoints for container")
 	}
@@ -280,12 +408,18 @@ func (b *Builder) Run(command []string, options RunOptions) error {
 	if runtime == "" {
 		runtime = DefaultRuntime
 	}
-	args := append(options.Args, "run", "-b", path, Package+"-"+b.ContainerID)
+	args := append(options.Args, "run", "-b", path, b.runtimeContainerName())
 	cmd := exec.Command(runtime, args...)
 	cmd.Dir = mountPoint
 	cmd.Stdin = os.Stdin
 	cmd.Stdout = os.Stdout
+	if options.Stdout != nil {
+		cmd.Stdout = options.Stdout
+	}
 	cmd.Stderr = os.Stderr
+	if options.Stderr != nil {
+		cmd.Stderr = options.Stderr
+	}
 	err = cmd.Run()
 	if err != nil {
 		logrus.Debugf("error running runc %v: %v", spec.Process.Args, err)
@@ -21,8 +21,26 @@ var (
 	// OverrideMountsFile holds the default mount paths in the form
 	// "host_path:container_path" overriden by the user
 	OverrideMountsFile = "/etc/containers/mounts.conf"
+	// UserMountsFile is the location, relative to the user's configuration
+	// directory, of a file which holds additional mount paths in the form
+	// "host_path:container_path" for that user
+	UserMountsFile = "containers/mounts.conf"
 )
 
+// userMountsFilePath returns the location of the current user's mounts file,
+// which is in $XDG_CONFIG_HOME, or $HOME/.config if that isn't set.
+func userMountsFilePath() string {
+	configHome := os.Getenv("XDG_CONFIG_HOME")
+	if configHome == "" {
+		home := os.Getenv("HOME")
+		if home == "" {
+			return ""
+		}
+		configHome = filepath.Join(home, ".config")
+	}
+	return filepath.Join(configHome, UserMountsFile)
+}
+
 // SecretData info
 type SecretData struct {
 	Name string
@@ -32,7 +50,7 @@ type SecretData struct {
 func getMounts(filePath string) []string {
 	file, err := os.Open(filePath)
 	if err != nil {
-		logrus.Warnf("file %q not found, skipping...", filePath)
+		logrus.Debugf("file %q not found, skipping...", filePath)
 		return nil
 	}
 	defer file.Close()
@@ -43,7 +61,11 @@ func getMounts(filePath string) []string {
 	}
 	var mounts []string
 	for scanner.Scan() {
-		mounts = append(mounts, scanner.Text())
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		mounts = append(mounts, line)
 	}
 	return mounts
 }
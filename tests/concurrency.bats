@@ -0,0 +1,44 @@
+#!/usr/bin/env bats
+
+load helpers
+
+@test "concurrent-from-unique-names" {
+  for i in $(seq 1 20) ; do
+    buildah from --signature-policy ${TESTSDIR}/policy.json scratch > ${TESTDIR}/from-$i.out &
+  done
+  wait
+  run sh -c "cat ${TESTDIR}/from-*.out | sort | uniq | wc -l"
+  [ "$status" -eq 0 ]
+  [ "$output" -eq 20 ]
+  run sh -c "buildah containers --quiet | wc -l"
+  [ "$output" -eq 20 ]
+  buildah rm $(cat ${TESTDIR}/from-*.out)
+}
+
+@test "concurrent-bud-same-tag" {
+  for i in $(seq 1 10) ; do
+    buildah bud --signature-policy ${TESTSDIR}/policy.json -t concurrent-image:$i -t concurrent-image:shared ${TESTSDIR}/bud/from-scratch > /dev/null &
+  done
+  wait
+  for i in $(seq 1 10) ; do
+    buildah inspect --type=image concurrent-image:$i > /dev/null
+  done
+  buildah inspect --type=image concurrent-image:shared > /dev/null
+  run buildah containers --quiet
+  [ "$output" = "" ]
+  buildah rmi -f $(buildah images --quiet)
+}
+
+@test "concurrent-tag" {
+  cid=$(buildah from --signature-policy ${TESTSDIR}/policy.json scratch)
+  buildah commit --signature-policy ${TESTSDIR}/policy.json $cid tagged-image
+  buildah rm $cid
+  for i in $(seq 1 20) ; do
+    buildah tag tagged-image tagged-image:$i &
+  done
+  wait
+  for i in $(seq 1 20) ; do
+    buildah inspect --type=image tagged-image:$i > /dev/null
+  done
+  buildah rmi -f tagged-image
+}
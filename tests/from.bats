@@ -112,3 +112,59 @@ load helpers
 #  buildah rm $ctrid
 #  buildah rmi -f $(buildah --debug=false images -q)
 }
+
+@test "from-host-policy" {
+  echo '{"denied": ["docker.io"]}' > ${TESTDIR}/deny-docker-io.json
+  run buildah from --pull-always --signature-policy ${TESTSDIR}/policy.json --host-policy ${TESTDIR}/deny-docker-io.json alpine
+  [ "$status" -ne 0 ]
+  echo "$output" | grep -q "host policy violation"
+  echo '{"allowed": ["registry.example.com"]}' > ${TESTDIR}/allow-example.json
+  run buildah from --pull-always --signature-policy ${TESTSDIR}/policy.json --host-policy ${TESTDIR}/allow-example.json alpine
+  [ "$status" -ne 0 ]
+  echo "$output" | grep -q "not allowed"
+  cid=$(buildah from --signature-policy ${TESTSDIR}/policy.json --host-policy ${TESTDIR}/allow-example.json scratch)
+  run buildah add $cid https://github.com/projectatomic/buildah/raw/master/README.md
+  [ "$status" -ne 0 ]
+  echo "$output" | grep -q "host policy violation"
+  buildah rm $cid
+  rm -f ${TESTDIR}/deny-docker-io.json ${TESTDIR}/allow-example.json
+}
+
+@test "from-blob-cache" {
+  elsewhere=${TESTDIR}/elsewhere-img
+  mkdir -p ${elsewhere}
+  cid=$(buildah from --pull --signature-policy ${TESTSDIR}/policy.json scratch)
+  buildah config --label cached=true $cid
+  buildah commit --signature-policy ${TESTSDIR}/policy.json $cid dir:${elsewhere}
+  buildah rm $cid
+
+  cid=$(buildah from --pull-always --blob-cache ${TESTDIR}/blobs --signature-policy ${TESTSDIR}/policy.json dir:${elsewhere})
+  buildah rm $cid
+  buildah rmi ${elsewhere}
+  [ -n "$(ls ${TESTDIR}/blobs/sha256)" ]
+
+  # The cached copies are used instead of the ones in the image's location.
+  for blob in ${TESTDIR}/blobs/sha256/* ; do
+    [ -s ${elsewhere}/$(basename $blob).tar ]
+    rm -f ${elsewhere}/$(basename $blob).tar
+  done
+  cid=$(buildah from --pull-always --blob-cache ${TESTDIR}/blobs --signature-policy ${TESTSDIR}/policy.json dir:${elsewhere})
+  buildah rm $cid
+  buildah rmi ${elsewhere}
+
+  run buildah from --blob-mirror ftp://example.com --signature-policy ${TESTSDIR}/policy.json scratch
+  [ "$status" -ne 0 ]
+  echo "$output" | grep -q "invalid registry mirror URL"
+}
+
+@test "from-host-engine" {
+  run buildah from --host-engine podman --signature-policy ${TESTSDIR}/policy.json scratch
+  [ "$status" -ne 0 ]
+  echo "$output" | grep -q "unsupported host engine"
+  run buildah from --host-engine containerd=${TESTDIR}/no-such-content-store --signature-policy ${TESTSDIR}/policy.json scratch
+  [ "$status" -ne 0 ]
+  echo "$output" | grep -q "error reading containerd content store"
+  mkdir -p ${TESTDIR}/content/blobs/sha256
+  cid=$(buildah from --host-engine containerd=${TESTDIR}/content --signature-policy ${TESTSDIR}/policy.json scratch)
+  buildah rm $cid
+}
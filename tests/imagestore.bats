@@ -0,0 +1,32 @@
+#!/usr/bin/env bats
+
+load helpers
+
+@test "image-store-unsupported-driver" {
+  if [ "$STORAGE_DRIVER" = overlay ] || [ "$STORAGE_DRIVER" = overlay2 ] ; then
+    skip "storage driver supports additional image stores"
+  fi
+  run buildah --image-store ${TESTDIR}/shared images
+  [ "$status" -ne 0 ]
+  echo "$output" | grep -q "not supported by the \"${STORAGE_DRIVER}\" storage driver"
+}
+
+@test "image-store" {
+  if [ "$STORAGE_DRIVER" != overlay ] && [ "$STORAGE_DRIVER" != overlay2 ] ; then
+    skip "storage driver does not support additional image stores"
+  fi
+  mkdir -p ${TESTDIR}/shared ${TESTDIR}/shared-runroot
+  cid=$(${BUILDAH_BINARY} --root ${TESTDIR}/shared --runroot ${TESTDIR}/shared-runroot --storage-driver ${STORAGE_DRIVER} from --signature-policy ${TESTSDIR}/policy.json scratch)
+  ${BUILDAH_BINARY} --root ${TESTDIR}/shared --runroot ${TESTDIR}/shared-runroot --storage-driver ${STORAGE_DRIVER} commit --signature-policy ${TESTSDIR}/policy.json $cid shared-image
+  ${BUILDAH_BINARY} --root ${TESTDIR}/shared --runroot ${TESTDIR}/shared-runroot --storage-driver ${STORAGE_DRIVER} rm $cid
+  run buildah --image-store ${TESTDIR}/shared images -q
+  [ "$status" -eq 0 ]
+  [ "${#lines[@]}" -eq 1 ]
+  cid=$(buildah --image-store ${TESTDIR}/shared from --pull=false --signature-policy ${TESTSDIR}/policy.json shared-image)
+  buildah --image-store ${TESTDIR}/shared commit --signature-policy ${TESTSDIR}/policy.json $cid local-image
+  buildah --image-store ${TESTDIR}/shared rm $cid
+  run buildah --image-store ${TESTDIR}/shared rmi shared-image
+  [ "$status" -ne 0 ]
+  buildah --image-store ${TESTDIR}/shared rmi local-image
+  ${BUILDAH_BINARY} --root ${TESTDIR}/shared --runroot ${TESTDIR}/shared-runroot --storage-driver ${STORAGE_DRIVER} rmi shared-image
+}
@@ -0,0 +1,47 @@
+#!/usr/bin/env bats
+
+load helpers
+
+@test "image-tree" {
+  cid=$(buildah from --pull=false --signature-policy ${TESTSDIR}/policy.json scratch)
+  buildah commit --signature-policy ${TESTSDIR}/policy.json "$cid" base-image
+  buildah rm "$cid"
+  cid=$(buildah from --pull=false --signature-policy ${TESTSDIR}/policy.json base-image)
+  buildah commit --signature-policy ${TESTSDIR}/policy.json "$cid" child-image
+  buildah rm "$cid"
+  cid=$(buildah from --pull=false --signature-policy ${TESTSDIR}/policy.json child-image)
+  buildah commit --signature-policy ${TESTSDIR}/policy.json "$cid" grandchild-image
+  run buildah --debug=false image-tree base-image
+  [ "$status" -eq 0 ]
+  [ "${#lines[@]}" -eq 4 ]
+  [[ "${lines[0]}" =~ "base-image" ]]
+  [[ "${lines[1]}" =~ "└── "[0-9a-f]*" "[^\ ]*"child-image" ]]
+  [[ "${lines[2]}" =~ "    ├── container ${cid}" ]]
+  [[ "${lines[3]}" =~ "grandchild-image" ]]
+  run buildah --debug=false image-tree grandchild-image
+  [ "$status" -eq 0 ]
+  [ "${#lines[@]}" -eq 1 ]
+  buildah rm "$cid"
+  buildah rmi grandchild-image child-image base-image
+}
+
+@test "commit-base-image" {
+  cid=$(buildah from --pull=false --signature-policy ${TESTSDIR}/policy.json scratch)
+  buildah commit --signature-policy ${TESTSDIR}/policy.json "$cid" base-image
+  buildah rm "$cid"
+  buildah push --signature-policy ${TESTSDIR}/policy.json base-image dir:${TESTDIR}/mirror
+  cid=$(buildah from --pull=false --signature-policy ${TESTSDIR}/policy.json base-image)
+  buildah commit --signature-policy ${TESTSDIR}/policy.json --base-image dir:${TESTDIR}/mirror "$cid" child-image
+  imgtype -show-manifest child-image | grep "org.opencontainers.image.base.name" | grep -q "dir:${TESTDIR}/mirror"
+
+  createrandom ${TESTDIR}/randomfile
+  other=$(buildah from --pull=false --signature-policy ${TESTSDIR}/policy.json scratch)
+  buildah copy "$other" ${TESTDIR}/randomfile
+  buildah commit --signature-policy ${TESTSDIR}/policy.json "$other" dir:${TESTDIR}/other
+  buildah rm "$other"
+  run buildah commit --signature-policy ${TESTSDIR}/policy.json --base-image dir:${TESTDIR}/other "$cid" other-child-image
+  [ "$status" -ne 0 ]
+  echo "$output" | grep -q "are not the same"
+  buildah rm "$cid"
+  buildah rmi child-image base-image
+}
@@ -243,3 +243,62 @@ load helpers
 	[ "$output" = "foobar" ]
 	buildah rm $cid
 }
+
+@test "run-workingdir-and-path" {
+	if ! which runc ; then
+		skip
+	fi
+	cid=$(buildah from --pull --signature-policy ${TESTSDIR}/policy.json alpine)
+	run buildah --debug=false run --workingdir /var $cid pwd
+	[ "$status" -eq 0 ]
+	[ "$output" = /var ]
+	run buildah --debug=false run --workingdir var $cid pwd
+	[ "$status" -ne 0 ]
+	buildah config $cid --env PATH=
+	run buildah --debug=false run $cid ls /
+	[ "$status" -eq 0 ]
+	run buildah --debug=false run $cid no-such-command
+	[ "$status" -ne 0 ]
+	echo "$output" | grep -q "not found in \$PATH"
+	buildah rm $cid
+}
+
+@test "run-init" {
+	if ! which runc ; then
+		skip
+	fi
+	if ! ( which catatonit || which tini-static || which docker-init ) ; then
+		skip
+	fi
+	cid=$(buildah from --pull --signature-policy ${TESTSDIR}/policy.json alpine)
+	run buildah --debug=false run --init $cid sh -c 'echo $$'
+	[ "$status" -eq 0 ]
+	[ "$output" != 1 ]
+	run buildah --debug=false run $cid sh -c 'echo $$'
+	[ "$status" -eq 0 ]
+	[ "$output" = 1 ]
+	run buildah --debug=false run --init --init-path /no/such/init $cid true
+	[ "$status" -ne 0 ]
+	buildah rm $cid
+}
+
+@test "exec" {
+	if ! which runc ; then
+		skip
+	fi
+	cid=$(buildah from --pull --signature-policy ${TESTSDIR}/policy.json alpine)
+	run buildah --debug=false exec --tty=false $cid true
+	[ "$status" -ne 0 ]
+	buildah run --tty=false $cid sleep 15 &
+	for i in $(seq 10) ; do
+		if buildah --debug=false exec --tty=false $cid test -d /proc/1 ; then
+			break
+		fi
+		sleep 1
+	done
+	run buildah --debug=false exec --tty=false $cid cat /proc/1/cmdline
+	[ "$status" -eq 0 ]
+	echo "$output" | grep -q sleep
+	wait
+	buildah rm $cid
+}
@@ -31,3 +31,24 @@ function setup() {
     rm -rf $TESTSDIR/containers
     rm -rf $TESTSDIR/rhel
 }
+
+@test "bud with secrets mounts" {
+    if ! which runc ; then
+		skip
+    fi
+    mkdir -p $TESTSDIR/bud-secrets
+    printf 'FROM alpine\nRUN cat /run/secrets/test.txt\n' > $TESTSDIR/bud-secrets/Dockerfile
+    run buildah --default-mounts-file "$MOUNTS_PATH" --debug=false bud --signature-policy ${TESTSDIR}/policy.json -t secrets-image $TESTSDIR/bud-secrets
+    echo "$output"
+    [ "$status" -eq 0 ]
+    run grep "I am mounted" <<< "$output"
+    [ "$status" -eq 0 ]
+    cid=$(buildah from secrets-image)
+    root=$(buildah mount $cid)
+    test ! -e $root/run/secrets/test.txt
+    buildah rm $cid
+    buildah rmi secrets-image
+    rm -rf $TESTSDIR/bud-secrets
+    rm -rf $TESTSDIR/containers
+    rm -rf $TESTSDIR/rhel
+}
@@ -17,3 +17,15 @@ load helpers
   run buildah inspect --type image named-image
   [ "$status" -eq 0 ]
 }
+
+@test "commit-auto-tag-from-label" {
+  cid=$(buildah from --pull=false --signature-policy ${TESTSDIR}/policy.json scratch)
+  buildah config --label org.opencontainers.image.version=1.2.3 "$cid"
+  run buildah commit --signature-policy ${TESTSDIR}/policy.json --auto-tag-from-label org.opencontainers.image.version "$cid" versioned-image:1.2.3
+  [ "$status" -eq 0 ]
+  for tag in 1 1.2 latest ; do
+    run buildah inspect --type image versioned-image:${tag}
+    [ "$status" -eq 0 ]
+  done
+  buildah rm "$cid"
+}
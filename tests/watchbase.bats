@@ -0,0 +1,20 @@
+#!/usr/bin/env bats
+
+load helpers
+
+@test "watch-base" {
+  cid=$(buildah from --pull=false --signature-policy ${TESTSDIR}/policy.json scratch)
+  buildah commit --signature-policy ${TESTSDIR}/policy.json "$cid" dir:${TESTDIR}/base
+  run buildah --debug=false watch-base --once --state ${TESTDIR}/state.json --exec 'echo "$BUILDAH_BASE_DIGEST" > '${TESTDIR}/digest dir:${TESTDIR}/base
+  [ "$status" -eq 0 ]
+  [ "$output" = "" ]
+  [ -s ${TESTDIR}/state.json ]
+  buildah config --label changed=true "$cid"
+  rm -fr ${TESTDIR}/base
+  buildah commit --signature-policy ${TESTSDIR}/policy.json "$cid" dir:${TESTDIR}/base
+  run buildah --debug=false watch-base --once --state ${TESTDIR}/state.json --exec 'echo "$BUILDAH_BASE_DIGEST" > '${TESTDIR}/digest dir:${TESTDIR}/base
+  [ "$status" -eq 0 ]
+  [[ "$output" =~ "dir:${TESTDIR}/base changed from sha256:" ]]
+  [[ "$(cat ${TESTDIR}/digest)" =~ ^sha256: ]]
+  buildah rm "$cid"
+}
@@ -1,10 +1,19 @@
 package buildah
 
 import (
+	"os"
+	"path/filepath"
+	"strings"
+
 	"github.com/containers/storage/pkg/chrootarchive"
 	"github.com/containers/storage/pkg/reexec"
+	"github.com/pkg/errors"
 )
 
+// maxSymlinks is the number of symbolic links which resolveInRoot will
+// follow before deciding that there's a loop.
+const maxSymlinks = 255
+
 var (
 	// CopyWithTar defines the copy method to use.
 	copyWithTar     = chrootarchive.NewArchiver(nil).CopyWithTar
@@ -32,3 +41,36 @@ func copyStringSlice(s []string) []string {
 	copy(t, s)
 	return t
 }
+
+// resolveInRoot returns the location on the host of the absolute path in the
+// tree rooted at root, following any symbolic links as though root were the
+// root directory, so that links can't point outside of root.
+func resolveInRoot(root, path string) (string, error) {
+	resolved := "/"
+	remaining := strings.Split(filepath.Clean("/"+path), "/")
+	for links := 0; len(remaining) > 0; {
+		component := remaining[0]
+		remaining = remaining[1:]
+		if component == "" || component == "." {
+			continue
+		}
+		candidate := filepath.Join(resolved, component)
+		finfo, err := os.Lstat(filepath.Join(root, candidate))
+		if err != nil || finfo.Mode()&os.ModeSymlink == 0 {
+			resolved = candidate
+			continue
+		}
+		if links++; links > maxSymlinks {
+			return "", errors.Errorf("too many levels of symbolic links resolving %q", path)
+		}
+		target, err := os.Readlink(filepath.Join(root, candidate))
+		if err != nil {
+			return "", errors.Wrapf(err, "error reading symbolic link %q", candidate)
+		}
+		if filepath.IsAbs(target) {
+			resolved = "/"
+		}
+		remaining = append(strings.Split(target, "/"), remaining...)
+	}
+	return filepath.Join(root, resolved), nil
+}
@@ -0,0 +1,27 @@
+package util
+
+import (
+	"path/filepath"
+
+	"github.com/containers/storage"
+	"github.com/pkg/errors"
+)
+
+// storeLockName is the name of the lock file, in the storage root directory,
+// which is used by LockStore.
+const storeLockName = "buildah.lock"
+
+// LockStore acquires a lock which is shared by every buildah process, and
+// every goroutine in this one, which uses the same storage.  It's used around
+// sequences of calls which read the state of the store and then change it
+// based on what they found, such as picking an unused name for a container or
+// adding names to an image, which the store's own locking doesn't make atomic.
+// It returns a function which releases the lock.
+func LockStore(store storage.Store) (func(), error) {
+	lock, err := storage.GetLockfile(filepath.Join(store.GraphRoot(), storeLockName))
+	if err != nil {
+		return nil, errors.Wrapf(err, "error opening lock for storage at %q", store.GraphRoot())
+	}
+	lock.Lock()
+	return lock.Unlock, nil
+}
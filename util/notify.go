@@ -0,0 +1,76 @@
+package util
+
+import (
+	"bytes"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// notifyTimeout is how long we wait for a webhook to accept an event.
+const notifyTimeout = 30 * time.Second
+
+// ParseNotificationTarget checks that we know how to deliver events to a
+// target, which can be an http or https URL to which events will be POSTed,
+// or the path of a file or named pipe to which they will be written, one per
+// line.  For URLs, it returns the host which would be contacted.
+func ParseNotificationTarget(target string) (host string, err error) {
+	if target == "" {
+		return "", errors.Errorf("empty notification target")
+	}
+	if !strings.Contains(target, "://") {
+		return "", nil
+	}
+	u, err := url.Parse(target)
+	if err != nil {
+		return "", errors.Wrapf(err, "error parsing notification target %q", target)
+	}
+	switch u.Scheme {
+	case "http", "https":
+		return u.Host, nil
+	}
+	return "", errors.Errorf("unsupported notification target %q: expected an http or https URL, or the path of a file or named pipe", target)
+}
+
+// SendNotification delivers an event, which should be encoded as JSON, to a
+// target which was checked using ParseNotificationTarget.
+func SendNotification(target string, event []byte) error {
+	if strings.HasPrefix(target, "http://") || strings.HasPrefix(target, "https://") {
+		return notifyURL(target, event)
+	}
+	return notifyFile(target, event)
+}
+
+// notifyURL POSTs an event to a webhook.
+func notifyURL(target string, data []byte) error {
+	client := http.Client{Timeout: notifyTimeout}
+	resp, err := client.Post(target, "application/json", bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return errors.Errorf("unexpected response %q", resp.Status)
+	}
+	return nil
+}
+
+// notifyFile appends an event to a file or writes it to a named pipe.  We
+// don't wait for a pipe to have a reader, since that could stall the caller
+// indefinitely.
+func notifyFile(target string, data []byte) error {
+	f, err := os.OpenFile(target, os.O_WRONLY|os.O_APPEND|os.O_CREATE|syscall.O_NONBLOCK, 0600)
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(append(data, '\n'))
+	if err2 := f.Close(); err == nil {
+		err = err2
+	}
+	return err
+}
@@ -0,0 +1,72 @@
+package util
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/containers/storage"
+	"github.com/pkg/errors"
+)
+
+const (
+	// StoreEnv is the name of the environment variable which can be set
+	// to EphemeralStore to have buildah use a throwaway store instead of
+	// the one which is configured for the system.
+	StoreEnv = "BUILDAH_STORE"
+	// EphemeralStore is the value of StoreEnv which selects a store which
+	// is created in a new temporary directory and removed when buildah
+	// exits.
+	EphemeralStore = "ephemeral"
+	// ephemeralStoreDriver is the storage driver used by ephemeral stores,
+	// since it works without root and on any filesystem, including tmpfs.
+	ephemeralStoreDriver = "vfs"
+	// ephemeralStoreTmpfs is where we prefer to create ephemeral stores.
+	ephemeralStoreTmpfs = "/dev/shm"
+)
+
+// UseEphemeralStore checks the StoreEnv environment variable, and returns true
+// if it requests an ephemeral store, or an error if it's set to a value which
+// we don't recognize.
+func UseEphemeralStore() (bool, error) {
+	switch value := os.Getenv(StoreEnv); value {
+	case "":
+		return false, nil
+	case EphemeralStore:
+		return true, nil
+	default:
+		return false, errors.Errorf("unrecognized value %q for $%s, expected %q", value, StoreEnv, EphemeralStore)
+	}
+}
+
+// NewEphemeralStore creates a store which uses the vfs driver in a new
+// temporary directory, on tmpfs if one is available, so that it can be used
+// without root and without affecting any other store.  It's intended for unit
+// tests and smoke tests.  The returned function shuts the store down and
+// removes everything in it.
+func NewEphemeralStore() (storage.Store, func() error, error) {
+	dir, err := ioutil.TempDir(ephemeralStoreTmpfs, "buildah-store-")
+	if err != nil {
+		if dir, err = ioutil.TempDir("", "buildah-store-"); err != nil {
+			return nil, nil, errors.Wrapf(err, "error creating directory for ephemeral store")
+		}
+	}
+	options := storage.StoreOptions{
+		RunRoot:         filepath.Join(dir, "run"),
+		GraphRoot:       filepath.Join(dir, "root"),
+		GraphDriverName: ephemeralStoreDriver,
+	}
+	store, err := storage.GetStore(options)
+	if err != nil {
+		os.RemoveAll(dir)
+		return nil, nil, errors.Wrapf(err, "error creating ephemeral store in %q", dir)
+	}
+	cleanup := func() error {
+		_, err := store.Shutdown(true)
+		if err2 := os.RemoveAll(dir); err2 != nil && err == nil {
+			err = errors.Wrapf(err2, "error removing ephemeral store in %q", dir)
+		}
+		return err
+	}
+	return store, cleanup, nil
+}
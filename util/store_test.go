@@ -0,0 +1,44 @@
+package util
+
+import (
+	"os"
+	"testing"
+)
+
+func TestUseEphemeralStore(t *testing.T) {
+	defer os.Setenv(StoreEnv, os.Getenv(StoreEnv))
+	for value, expected := range map[string]bool{
+		"":             false,
+		EphemeralStore: true,
+	} {
+		os.Setenv(StoreEnv, value)
+		ephemeral, err := UseEphemeralStore()
+		if err != nil {
+			t.Errorf("unexpected error for $%s=%q: %v", StoreEnv, value, err)
+		}
+		if ephemeral != expected {
+			t.Errorf("expected $%s=%q to return %v, got %v", StoreEnv, value, expected, ephemeral)
+		}
+	}
+	os.Setenv(StoreEnv, "persistent")
+	if _, err := UseEphemeralStore(); err == nil {
+		t.Errorf("expected an error for $%s=%q", StoreEnv, "persistent")
+	}
+}
+
+func TestNewEphemeralStore(t *testing.T) {
+	store, remove, err := NewEphemeralStore()
+	if err != nil {
+		t.Fatalf("error creating ephemeral store: %v", err)
+	}
+	root := store.GraphRoot()
+	if _, err = os.Stat(root); err != nil {
+		t.Fatalf("expected store root %q to exist: %v", root, err)
+	}
+	if err = remove(); err != nil {
+		t.Fatalf("error removing ephemeral store: %v", err)
+	}
+	if _, err = os.Stat(root); !os.IsNotExist(err) {
+		t.Errorf("expected store root %q to be removed, got %v", root, err)
+	}
+}
@@ -1,17 +1,113 @@
 package util
 
 import (
+	"regexp"
+	"strings"
+
 	"github.com/containers/image/docker/reference"
 	is "github.com/containers/image/storage"
 	"github.com/containers/storage"
 	"github.com/pkg/errors"
 )
 
+// maxTagLength is the longest tag which a registry will accept.
+const maxTagLength = 128
+
+// ValidateTag checks that a tag, without the name of the repository which it
+// would be attached to, contains only characters which are allowed in tags.
+func ValidateTag(tag string) error {
+	if tag == "" {
+		return errors.Errorf("tag must not be empty")
+	}
+	if len(tag) > maxTagLength {
+		return errors.Errorf("tag %q is %d characters long, but tags may not be longer than %d characters", tag, len(tag), maxTagLength)
+	}
+	for i, c := range tag {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9', c == '_':
+		case (c == '.' || c == '-') && i > 0:
+		default:
+			return errors.Errorf("invalid character %q at position %d in tag %q", c, i+1, tag)
+		}
+	}
+	return nil
+}
+
+// ValidateName checks that an image name, which may include a tag or digest,
+// can be parsed, and returns a specific error if it can't.
+func ValidateName(name string) error {
+	if name == "" {
+		return errors.Errorf("image name must not be empty")
+	}
+	repo := name
+	if i := strings.Index(repo, "@"); i != -1 {
+		repo = repo[:i]
+	}
+	if i := strings.LastIndex(repo, ":"); i != -1 && !strings.Contains(repo[i+1:], "/") {
+		if err := ValidateTag(repo[i+1:]); err != nil {
+			return errors.Wrapf(err, "invalid image name %q", name)
+		}
+	}
+	if _, err := reference.ParseNormalizedNamed(name); err != nil {
+		return errors.Wrapf(err, "invalid image name %q", name)
+	}
+	return nil
+}
+
+// NormalizeName parses an image name, adding the default registry and
+// "library/" namespace if it doesn't specify a registry, and the "latest" tag
+// if it specifies neither a tag nor a digest.
+func NormalizeName(name string) (string, error) {
+	if err := ValidateName(name); err != nil {
+		return "", err
+	}
+	named, err := reference.ParseNormalizedNamed(name)
+	if err != nil {
+		return "", errors.Wrapf(err, "error parsing image name %q", name)
+	}
+	return reference.TagNameOnly(named).String(), nil
+}
+
+// versionRegexp matches semantic version strings, with an optional leading
+// "v", optional pre-release information, and optional build metadata.
+var versionRegexp = regexp.MustCompile(`^v?(0|[1-9][0-9]*)\.(0|[1-9][0-9]*)\.(0|[1-9][0-9]*)(-[0-9A-Za-z.-]+)?(\+[0-9A-Za-z.-]+)?$`)
+
+// VersionTags parses a semantic version, and returns a list of names for the
+// named repository which are tagged with the major version, the major and
+// minor versions, and "latest".  Pre-release versions are not given any
+// additional names.
+func VersionTags(name, version string) ([]string, error) {
+	named, err := reference.ParseNormalizedNamed(name)
+	if err != nil {
+		return nil, errors.Wrapf(err, "error parsing image name %q", name)
+	}
+	named = reference.TrimNamed(named)
+	parts := versionRegexp.FindStringSubmatch(version)
+	if parts == nil {
+		return nil, errors.Errorf("%q is not a semantic version", version)
+	}
+	if parts[4] != "" {
+		return nil, nil
+	}
+	tags := []string{}
+	for _, tag := range []string{parts[1], parts[1] + "." + parts[2], "latest"} {
+		tagged, err := reference.WithTag(named, tag)
+		if err != nil {
+			return nil, errors.Wrapf(err, "error tagging %q with %q", named.String(), tag)
+		}
+		tags = append(tags, tagged.String())
+	}
+	return tags, nil
+}
+
 // ExpandTags takes unqualified names, parses them as image names, and returns
 // the fully expanded result, including a tag.
 func ExpandTags(tags []string) ([]string, error) {
 	expanded := []string{}
 	for _, tag := range tags {
+		if err := ValidateName(tag); err != nil {
+			return nil, err
+		}
 		name, err := reference.ParseNormalizedNamed(tag)
 		if err != nil {
 			return nil, errors.Wrapf(err, "error parsing tag %q", tag)
@@ -46,13 +142,23 @@ func FindImage(store storage.Store, image string) (*storage.Image, error) {
 	return img, nil
 }
 
-// AddImageNames adds the specified names to the specified image.
+// AddImageNames adds the specified names to the specified image, preserving
+// any names which other processes have added to it since it was read.
 func AddImageNames(store storage.Store, image *storage.Image, addNames []string) error {
 	names, err := ExpandTags(addNames)
 	if err != nil {
 		return err
 	}
-	err = store.SetNames(image.ID, append(image.Names, names...))
+	unlock, err := LockStore(store)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	current, err := store.Image(image.ID)
+	if err != nil {
+		return errors.Wrapf(err, "error reading image %q", image.ID)
+	}
+	err = store.SetNames(image.ID, append(current.Names, names...))
 	if err != nil {
 		return errors.Wrapf(err, "error adding names (%v) to image %q", names, image.ID)
 	}
@@ -0,0 +1,71 @@
+package util
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidateTag(t *testing.T) {
+	for _, tag := range []string{"latest", "1.0", "v1.0-rc_1", "_private"} {
+		if err := ValidateTag(tag); err != nil {
+			t.Errorf("expected tag %q to be accepted, got %v", tag, err)
+		}
+	}
+	for tag, message := range map[string]string{
+		"":                                  "must not be empty",
+		".hidden":                           "position 1",
+		"1.0+build":                         "position 4",
+		strings.Repeat("a", maxTagLength+1): "characters long",
+	} {
+		err := ValidateTag(tag)
+		if err == nil {
+			t.Errorf("expected tag %q to be rejected", tag)
+			continue
+		}
+		if !strings.Contains(err.Error(), message) {
+			t.Errorf("expected error for tag %q to mention %q, got %v", tag, message, err)
+		}
+	}
+}
+
+func TestNormalizeName(t *testing.T) {
+	for name, expected := range map[string]string{
+		"busybox":                          "docker.io/library/busybox:latest",
+		"busybox:musl":                     "docker.io/library/busybox:musl",
+		"example.com/busybox":              "example.com/busybox:latest",
+		"localhost:5000/busybox":           "localhost:5000/busybox:latest",
+		"localhost:5000/project/busybox:1": "localhost:5000/project/busybox:1",
+	} {
+		normalized, err := NormalizeName(name)
+		if err != nil {
+			t.Errorf("error normalizing %q: %v", name, err)
+			continue
+		}
+		if normalized != expected {
+			t.Errorf("expected %q to be normalized to %q, got %q", name, expected, normalized)
+		}
+	}
+	for _, name := range []string{"", "BusyBox", "busybox:1.0+build", "busybox:"} {
+		if _, err := NormalizeName(name); err == nil {
+			t.Errorf("expected %q to be rejected", name)
+		}
+	}
+}
+
+func TestVersionTags(t *testing.T) {
+	tags, err := VersionTags("example.com/app:build", "v1.2.3+meta")
+	if err != nil {
+		t.Fatalf("error computing version tags: %v", err)
+	}
+	expected := []string{"example.com/app:1", "example.com/app:1.2", "example.com/app:latest"}
+	if strings.Join(tags, " ") != strings.Join(expected, " ") {
+		t.Errorf("expected tags %v, got %v", expected, tags)
+	}
+	tags, err = VersionTags("app", "1.2.3-rc1")
+	if err != nil || len(tags) != 0 {
+		t.Errorf("expected no tags for a pre-release version, got %v (%v)", tags, err)
+	}
+	if _, err = VersionTags("app", "1.2"); err == nil {
+		t.Errorf("expected an incomplete version to be rejected")
+	}
+}
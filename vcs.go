@@ -0,0 +1,121 @@
+package buildah
+
+import (
+	"bytes"
+	"net/url"
+	"os/exec"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// VCSRevisionLabel is the label and annotation in which we record the
+	// revision of the source code which an image was built from.
+	VCSRevisionLabel = "org.opencontainers.image.revision"
+	// VCSSourceLabel is the label and annotation in which we record the
+	// URL of the repository which an image's source code came from.
+	VCSSourceLabel = "org.opencontainers.image.source"
+)
+
+// VCSInfo describes the git checkout which a directory is part of.
+type VCSInfo struct {
+	// Revision is the ID of the commit which is checked out.
+	Revision string
+	// Branch is the name of the branch which is checked out, or empty if
+	// none is.
+	Branch string
+	// Source is the URL of the remote repository which the branch tracks,
+	// or of the "origin" remote, without any credentials which it
+	// included.  It is empty if there is no such remote.
+	Source string
+	// Dirty is true if the checkout has uncommitted changes, so that
+	// Revision doesn't completely describe its contents.
+	Dirty bool
+}
+
+// git runs a git command in a directory and returns its trimmed output.
+func git(dir string, args ...string) (string, error) {
+	cmd := exec.Command("git", append([]string{"-C", dir}, args...)...)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return "", errors.Wrapf(err, "error running git %s: %s", strings.Join(args, " "), msg)
+		}
+		return "", errors.Wrapf(err, "error running git %s", strings.Join(args, " "))
+	}
+	return strings.TrimSpace(stdout.String()), nil
+}
+
+// DetectVCS examines the git checkout which a directory is part of.  It
+// returns nil, and no error, if the directory isn't part of one.
+func DetectVCS(dir string) (*VCSInfo, error) {
+	if _, err := exec.LookPath("git"); err != nil {
+		return nil, errors.Wrapf(err, "error finding git")
+	}
+	if inside, err := git(dir, "rev-parse", "--is-inside-work-tree"); err != nil || inside != "true" {
+		return nil, nil
+	}
+	revision, err := git(dir, "rev-parse", "HEAD")
+	if err != nil {
+		return nil, err
+	}
+	info := &VCSInfo{Revision: revision}
+	if branch, err := git(dir, "symbolic-ref", "--short", "-q", "HEAD"); err == nil {
+		info.Branch = branch
+	}
+	remote := ""
+	if info.Branch != "" {
+		remote, _ = git(dir, "config", "--get", "branch."+info.Branch+".remote")
+	}
+	if remote == "" || remote == "." {
+		remote = "origin"
+	}
+	if source, err := git(dir, "config", "--get", "remote."+remote+".url"); err == nil {
+		info.Source = sanitizeSourceURL(source)
+	}
+	status, err := git(dir, "status", "--porcelain", "--untracked-files=no")
+	if err != nil {
+		return nil, err
+	}
+	info.Dirty = status != ""
+	return info, nil
+}
+
+// sanitizeSourceURL removes credentials from a remote's URL.  URLs which use
+// ssh keep their user names, which are usually a fixed value like "git".
+func sanitizeSourceURL(source string) string {
+	u, err := url.Parse(source)
+	if err != nil || u.Scheme == "" || u.User == nil {
+		return source
+	}
+	if u.Scheme == "ssh" || strings.HasPrefix(u.Scheme, "git+ssh") {
+		u.User = url.User(u.User.Username())
+	} else {
+		u.User = nil
+	}
+	return u.String()
+}
+
+// SetVCSLabels records the revision and source repository which are described
+// by info in the image's labels and in its manifest's annotations, replacing
+// any values which were inherited from the base image.
+func (b *Builder) SetVCSLabels(info *VCSInfo) {
+	if info == nil {
+		return
+	}
+	for key, value := range map[string]string{
+		VCSRevisionLabel: info.Revision,
+		VCSSourceLabel:   info.Source,
+	} {
+		if value == "" {
+			b.UnsetLabel(key)
+			b.UnsetAnnotation(key)
+			continue
+		}
+		b.SetLabel(key, value)
+		b.SetAnnotation(key, value)
+	}
+}
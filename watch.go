@@ -0,0 +1,164 @@
+package buildah
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"time"
+
+	"github.com/containers/image/manifest"
+	"github.com/containers/image/transports"
+	"github.com/containers/image/types"
+	"github.com/containers/storage/pkg/ioutils"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+// BaseImageUpdate describes a change to the image which an image reference
+// refers to.
+type BaseImageUpdate struct {
+	// Image is the reference which is being watched.
+	Image string `json:"image"`
+	// PreviousDigest is the digest of the image's manifest when it was
+	// last checked.
+	PreviousDigest string `json:"previous-digest"`
+	// Digest is the digest of the image's manifest now.
+	Digest string `json:"digest"`
+	// Time is when the change was noticed.
+	Time time.Time `json:"time"`
+}
+
+// WatchOptions controls how WatchBaseImages checks images for changes.
+type WatchOptions struct {
+	// Images is the list of image references to watch.  References which
+	// don't include a transport are looked up in registries.
+	Images []string
+	// Interval is how long to wait between checks.
+	Interval time.Duration
+	// Once causes the images to only be checked once.
+	Once bool
+	// StatePath is the location of a file in which the digests of the
+	// images are recorded, so that changes which happen while we're not
+	// watching can be noticed.  If it is not set, the digests which are
+	// found during the first check are assumed to be current.
+	StatePath string
+	// SystemContext is used when reading images.
+	SystemContext *types.SystemContext
+	// HostPolicy restricts which registries we can contact.
+	HostPolicy *HostPolicy
+	// Stop, if set, causes WatchBaseImages to return when it is closed.
+	Stop <-chan struct{}
+	// OnUpdate is called when an image changes.  If it returns an error,
+	// the change is reported again after the next check.
+	OnUpdate func(update BaseImageUpdate) error
+}
+
+// ImageDigest reads the manifest of an image, which is usually in a registry,
+// and returns its digest.
+func ImageDigest(image string, sc *types.SystemContext, policy *HostPolicy) (string, error) {
+	ref, err := parseImageName(image)
+	if err != nil {
+		return "", err
+	}
+	if err = policy.CheckReference("pull", ref); err != nil {
+		return "", err
+	}
+	src, err := ref.NewImageSource(sc)
+	if err != nil {
+		return "", errors.Wrapf(err, "error reading image %q", transports.ImageName(ref))
+	}
+	defer src.Close()
+	manifestBytes, _, err := src.GetManifest()
+	if err != nil {
+		return "", errors.Wrapf(err, "error reading manifest of image %q", transports.ImageName(ref))
+	}
+	d, err := manifest.Digest(manifestBytes)
+	if err != nil {
+		return "", errors.Wrapf(err, "error computing digest of manifest of image %q", transports.ImageName(ref))
+	}
+	return d.String(), nil
+}
+
+// readWatchState reads the digests which were recorded by an earlier call to
+// WatchBaseImages.
+func readWatchState(path string) (map[string]string, error) {
+	state := make(map[string]string)
+	if path == "" {
+		return state, nil
+	}
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return state, nil
+		}
+		return nil, errors.Wrapf(err, "error reading watch state from %q", path)
+	}
+	if err = json.Unmarshal(data, &state); err != nil {
+		return nil, errors.Wrapf(err, "error parsing watch state in %q", path)
+	}
+	return state, nil
+}
+
+// WatchBaseImages periodically checks a set of images for changes, and calls
+// options.OnUpdate for each image which has changed since the last check.
+// Images which can't be checked are skipped until the next check.  Unless
+// options.Once is set, it only returns if options.Stop is closed, or if the
+// state file can't be read or written.
+func WatchBaseImages(options WatchOptions) error {
+	if len(options.Images) == 0 {
+		return errors.Errorf("no images specified")
+	}
+	if options.Interval <= 0 && !options.Once {
+		return errors.Errorf("invalid interval %v", options.Interval)
+	}
+	state, err := readWatchState(options.StatePath)
+	if err != nil {
+		return err
+	}
+	for {
+		changed := false
+		for _, image := range options.Images {
+			digest, err := ImageDigest(image, options.SystemContext, options.HostPolicy)
+			if err != nil {
+				logrus.Errorf("error checking %q for changes: %v", image, err)
+				continue
+			}
+			previous, known := state[image]
+			if known && previous == digest {
+				continue
+			}
+			logrus.Debugf("%q is now %s", image, digest)
+			if known && options.OnUpdate != nil {
+				update := BaseImageUpdate{
+					Image:          image,
+					PreviousDigest: previous,
+					Digest:         digest,
+					Time:           time.Now().UTC(),
+				}
+				if err = options.OnUpdate(update); err != nil {
+					logrus.Errorf("error handling update to %q: %v", image, err)
+					continue
+				}
+			}
+			state[image] = digest
+			changed = true
+		}
+		if changed && options.StatePath != "" {
+			data, err := json.Marshal(state)
+			if err != nil {
+				return errors.Wrapf(err, "error encoding watch state")
+			}
+			if err = ioutils.AtomicWriteFile(options.StatePath, data, 0600); err != nil {
+				return errors.Wrapf(err, "error saving watch state to %q", options.StatePath)
+			}
+		}
+		if options.Once {
+			return nil
+		}
+		select {
+		case <-options.Stop:
+			return nil
+		case <-time.After(options.Interval):
+		}
+	}
+}
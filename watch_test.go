@@ -0,0 +1,84 @@
+package buildah
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestWatchBaseImages(t *testing.T) {
+	dir, err := ioutil.TempDir("", "buildah-watch")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	imageDir := filepath.Join(dir, "image")
+	if err = os.Mkdir(imageDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	writeManifest := func(manifest string) {
+		if err := ioutil.WriteFile(filepath.Join(imageDir, "manifest.json"), []byte(manifest), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	image := "dir:" + imageDir
+	updates := []BaseImageUpdate{}
+	fail := false
+	options := WatchOptions{
+		Images:    []string{image},
+		Once:      true,
+		StatePath: filepath.Join(dir, "state.json"),
+		OnUpdate: func(update BaseImageUpdate) error {
+			updates = append(updates, update)
+			if fail {
+				return os.ErrInvalid
+			}
+			return nil
+		},
+	}
+
+	// The first check only records the digest.
+	writeManifest(`{"schemaVersion":2,"layers":[]}`)
+	first, err := ImageDigest(image, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = WatchBaseImages(options); err != nil {
+		t.Fatal(err)
+	}
+	if len(updates) != 0 {
+		t.Fatalf("expected no updates after the first check, got %v", updates)
+	}
+
+	// A change is noticed using the saved state, and is reported again if
+	// it isn't handled.
+	writeManifest(`{"schemaVersion":2,"layers":[{}]}`)
+	second, err := ImageDigest(image, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fail = true
+	for i := 1; i <= 2; i++ {
+		if err = WatchBaseImages(options); err != nil {
+			t.Fatal(err)
+		}
+		if len(updates) != i {
+			t.Fatalf("expected %d updates, got %v", i, updates)
+		}
+	}
+	fail = false
+	if err = WatchBaseImages(options); err != nil {
+		t.Fatal(err)
+	}
+	if err = WatchBaseImages(options); err != nil {
+		t.Fatal(err)
+	}
+	if len(updates) != 3 {
+		t.Fatalf("expected 3 updates, got %v", updates)
+	}
+	if updates[2].Image != image || updates[2].PreviousDigest != first || updates[2].Digest != second {
+		t.Errorf("unexpected update %+v, expected %q to change from %q to %q", updates[2], image, first, second)
+	}
+}